@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	BinarySkip   = "skip"
+	BinaryBase64 = "base64"
+	BinaryHex    = "hex"
+	JsonPretty   = "pretty"
+	JsonCompact  = "compact"
+)
+
+// IsText reports whether a body of the content type can be logged as a string. If the content type is empty, the body is sniffed.
+func IsText(contentType string, body string) bool {
+	if len(contentType) == 0 {
+		n := len(body)
+		if n > 512 {
+			n = 512
+		}
+		contentType = http.DetectContentType([]byte(body[:n]))
+		if strings.HasPrefix(contentType, "application/octet-stream") {
+			return utf8.ValidString(body[:n])
+		}
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.ToLower(contentType)
+	}
+	if strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+	switch mt {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded", "application/javascript", "application/yaml", "application/x-yaml", "application/graphql":
+		return true
+	}
+	return false
+}
+
+// FormatBody formats a body to be logged: binary content is summarized, JSON is normalized, then the result is truncated to conf.MaxBodySize.
+// A body already truncated when it was read (see LogInterceptor) is formatted without its marker, then the marker is appended again.
+func FormatBody(conf *LogConfig, contentType string, body string) string {
+	if conf == nil || len(body) == 0 {
+		return body
+	}
+	body, marker, size := splitTruncated(body)
+	if !IsText(contentType, body) {
+		switch conf.Binary {
+		case BinaryBase64:
+			body = base64.StdEncoding.EncodeToString([]byte(body))
+		case BinaryHex:
+			body = hex.EncodeToString([]byte(body))
+		default:
+			if size < 0 {
+				return fmt.Sprintf("[binary %s, more than %d bytes]", contentTypeOrUnknown(contentType), len(body))
+			}
+			return fmt.Sprintf("[binary %s, %d bytes]", contentTypeOrUnknown(contentType), size)
+		}
+	} else if len(conf.JsonFormat) > 0 {
+		t := strings.TrimSpace(body)
+		if len(t) > 0 && (t[0] == '{' || t[0] == '[') {
+			buf := new(bytes.Buffer)
+			var err error
+			if conf.JsonFormat == JsonPretty {
+				err = json.Indent(buf, []byte(t), "", "  ")
+			} else {
+				err = json.Compact(buf, []byte(t))
+			}
+			if err == nil {
+				body = buf.String()
+			}
+		}
+	}
+	if len(marker) > 0 {
+		return body + marker
+	}
+	return Truncate(body, conf.MaxBodySize)
+}
+
+// Truncate cuts s to max bytes (at a rune boundary) and appends a marker with the number of removed bytes.
+func Truncate(s string, max int) string {
+	return truncate(s, max, int64(len(s)))
+}
+
+// truncate cuts s to max bytes (at a rune boundary) and appends a marker with the number of removed bytes of the body of size bytes,
+// or a marker without number if size is negative (unknown).
+func truncate(s string, max int, size int64) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	if size < 0 {
+		return s[:n] + "...[truncated]"
+	}
+	return fmt.Sprintf("%s...[truncated %d bytes]", s[:n], size-int64(n))
+}
+
+var truncatedMarker = regexp.MustCompile(`\.\.\.\[truncated(?: (\d+) bytes)?\]$`)
+
+// splitTruncated splits a truncated body into its content and its marker, and returns the size of the original body, -1 if it is unknown.
+func splitTruncated(s string) (string, string, int64) {
+	loc := truncatedMarker.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return s, "", int64(len(s))
+	}
+	if loc[2] < 0 {
+		return s[:loc[0]], s[loc[0]:], -1
+	}
+	n, err := strconv.ParseInt(s[loc[2]:loc[3]], 10, 64)
+	if err != nil {
+		return s, "", int64(len(s))
+	}
+	return s[:loc[0]], s[loc[0]:], int64(loc[0]) + n
+}
+
+// FormatDump formats the body of a dump of http.Response, using its Content-Type header.
+func FormatDump(conf *LogConfig, s string) (string, int) {
+	i := strings.Index(s, "\r\n\r\n")
+	if i < 0 || !strings.HasPrefix(s, "HTTP/") {
+		_, _, size := splitTruncated(s)
+		return FormatBody(conf, "", s), int(size)
+	}
+	head, body := s[:i], s[i+4:]
+	var contentType string
+	for _, line := range strings.Split(head, "\r\n")[1:] {
+		if j := strings.Index(line, ":"); j > 0 && strings.EqualFold(strings.TrimSpace(line[:j]), "Content-Type") {
+			contentType = strings.TrimSpace(line[j+1:])
+		}
+	}
+	_, _, size := splitTruncated(body)
+	return head + "\r\n\r\n" + FormatBody(conf, contentType, body), int(size)
+}
+
+// FormatLog wraps a log function to format the request and response fields of conf, and to set the original size of the response into the size field,
+// if it is known.
+func FormatLog(conf *LogConfig, log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+	if log == nil || conf == nil || (conf.MaxBodySize <= 0 && len(conf.Binary) == 0 && len(conf.JsonFormat) == 0) {
+		return log
+	}
+	return func(ctx context.Context, msg string, fields map[string]interface{}) {
+		if len(conf.Request) > 0 {
+			if s, ok := fields[conf.Request].(string); ok {
+				fields[conf.Request] = FormatBody(conf, "", s)
+			}
+		}
+		if len(conf.Response) > 0 {
+			if s, ok := fields[conf.Response].(string); ok {
+				f, size := FormatDump(conf, s)
+				fields[conf.Response] = f
+				if len(conf.Size) > 0 && size >= 0 {
+					fields[conf.Size] = size
+				}
+			}
+		}
+		log(ctx, msg, fields)
+	}
+}
+
+// LogOptions wraps the log functions, to redact then format the logged fields.
+func LogOptions(conf *LogConfig, options []func(context.Context, string, map[string]interface{})) []func(context.Context, string, map[string]interface{}) {
+	return wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return RedactLog(conf, FormatLog(conf, log))
+	})
+}
+
+// wrapOptions wraps each log function by wrap; a nil log function is kept nil.
+func wrapOptions(options []func(context.Context, string, map[string]interface{}), wrap func(func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{})) []func(context.Context, string, map[string]interface{}) {
+	opts := make([]func(context.Context, string, map[string]interface{}), len(options))
+	for i, log := range options {
+		if log != nil {
+			opts[i] = wrap(log)
+		}
+	}
+	return opts
+}
+
+func contentTypeOrUnknown(contentType string) string {
+	if len(contentType) == 0 {
+		return "unknown"
+	}
+	return contentType
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 0, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 5, "hello...[truncated 6 bytes]"},
+		{"héllo", 2, "h...[truncated 5 bytes]"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        LogConfig
+		contentType string
+		body        string
+		want        string
+	}{
+		{"compact", LogConfig{JsonFormat: JsonCompact}, "", `{ "a": 1 }`, `{"a":1}`},
+		{"truncated", LogConfig{MaxBodySize: 4}, "", `{"a":1}`, `{"a"...[truncated 3 bytes]`},
+		{"already truncated", LogConfig{MaxBodySize: 4}, "", `{"a"...[truncated 3 bytes]`, `{"a"...[truncated 3 bytes]`},
+		{"unknown size", LogConfig{MaxBodySize: 4}, "", `{"a"...[truncated]`, `{"a"...[truncated]`},
+		{"binary", LogConfig{}, "image/png", "\x89PNG", "[binary image/png, 4 bytes]"},
+		{"binary truncated", LogConfig{MaxBodySize: 4}, "image/png", "\x89PNG...[truncated 96 bytes]", "[binary image/png, 100 bytes]"},
+		{"hex", LogConfig{Binary: BinaryHex}, "application/octet-stream", "\x01\x02", "0102"},
+	}
+	for _, tt := range tests {
+		if got := FormatBody(&tt.conf, tt.contentType, tt.body); got != tt.want {
+			t.Errorf("%s: FormatBody = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLogInterceptorReadsAtMostMaxBodySize(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("abc"))
+	}))
+	defer srv.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	var fields map[string]interface{}
+	conf := &LogConfig{Log: true, Response: "response", Size: "size", MaxBodySize: 4}
+	logInfo := func(ctx context.Context, msg string, f map[string]interface{}) { fields = f }
+	done := make(chan *http.Response)
+	go func() {
+		res, err := DoAndLog(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, conf, nil, logInfo)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+	var res *http.Response
+	select {
+	case res = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the streamed body must not be read before it is logged")
+	}
+	if s, _ := fields["response"].(string); !strings.HasSuffix(s, "\r\n\r\n0123...[truncated]") {
+		t.Errorf("unexpected response field %q", s)
+	}
+	if _, ok := fields["size"]; ok {
+		t.Errorf("the size of a chunked body is unknown, got %v", fields["size"])
+	}
+	close(release)
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(b) != "0123456789abc" {
+		t.Errorf("the caller must read the whole body, got %q", b)
+	}
+}
+
+func TestLogTruncatedResponseIsRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"user":"tom","password":"hunter2","message":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer srv.Close()
+
+	var fields map[string]interface{}
+	conf := &LogConfig{Response: "response", MaxBodySize: 50, Redact: &RedactConfig{Fields: []string{"password"}}}
+	logError := func(ctx context.Context, msg string, f map[string]interface{}) { fields = f }
+	res, err := DoAndLog(context.Background(), srv.Client(), http.MethodPost, srv.URL, []byte(`{"password":"hunter2"}`), nil, conf, logError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res.Body)
+	s, _ := fields["response"].(string)
+	if strings.Contains(s, "hunter2") || !strings.Contains(s, `"password":"***"`) || !strings.Contains(s, "...[truncated") {
+		t.Errorf("the truncated response must be redacted, got %q", s)
+	}
+}
@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	NetworkTimeout  = "timeout"
+	NetworkCanceled = "canceled"
+	NetworkDNS      = "dns"
+	NetworkRefused  = "refused"
+	NetworkReset    = "reset"
+	NetworkTLS      = "tls"
+	NetworkEOF      = "eof"
+	NetworkOther    = "network"
+)
+
+type ErrorRule struct {
+	Status    string `yaml:"status" mapstructure:"status" json:"status,omitempty" gorm:"column:status" bson:"status,omitempty" dynamodbav:"status,omitempty" firestore:"status,omitempty"`
+	Network   string `yaml:"network" mapstructure:"network" json:"network,omitempty" gorm:"column:network" bson:"network,omitempty" dynamodbav:"network,omitempty" firestore:"network,omitempty"`
+	Path      string `yaml:"path" mapstructure:"path" json:"path,omitempty" gorm:"column:path" bson:"path,omitempty" dynamodbav:"path,omitempty" firestore:"path,omitempty"`
+	Value     string `yaml:"value" mapstructure:"value" json:"value,omitempty" gorm:"column:value" bson:"value,omitempty" dynamodbav:"value,omitempty" firestore:"value,omitempty"`
+	ErrorType string `yaml:"error_type" mapstructure:"error_type" json:"errorType,omitempty" gorm:"column:errortype" bson:"errorType,omitempty" dynamodbav:"errorType,omitempty" firestore:"errorType,omitempty"`
+	ErrorCode string `yaml:"error_code" mapstructure:"error_code" json:"errorCode,omitempty" gorm:"column:errorcode" bson:"errorCode,omitempty" dynamodbav:"errorCode,omitempty" firestore:"errorCode,omitempty"`
+	Severity  string `yaml:"severity" mapstructure:"severity" json:"severity,omitempty" gorm:"column:severity" bson:"severity,omitempty" dynamodbav:"severity,omitempty" firestore:"severity,omitempty"`
+}
+
+// ErrorRules fills ErrorType, ErrorCode, Service and Severity of every outbound failure: the errors returned by the helpers,
+// and the 4xx, 5xx responses accepted by the status policy, which are classified to be logged (see LogInterceptor), but are not returned as errors.
+// Rules are evaluated in order; a field which is already set is never overridden by a later rule.
+type ErrorRules struct {
+	Service  string      `yaml:"service" mapstructure:"service" json:"service,omitempty" gorm:"column:service" bson:"service,omitempty" dynamodbav:"service,omitempty" firestore:"service,omitempty"`
+	Severity string      `yaml:"severity" mapstructure:"severity" json:"severity,omitempty" gorm:"column:severity" bson:"severity,omitempty" dynamodbav:"severity,omitempty" firestore:"severity,omitempty"`
+	Rules    []ErrorRule `yaml:"rules" mapstructure:"rules" json:"rules,omitempty" gorm:"column:rules" bson:"rules,omitempty" dynamodbav:"rules,omitempty" firestore:"rules,omitempty"`
+}
+
+// Classify applies the rules to err. A network error is converted to HttpError with status code 0, so that it can be classified too.
+func Classify(rules *ErrorRules, err error, duration int64, url string, request []byte) error {
+	if rules == nil || err == nil {
+		return err
+	}
+	e, ok := IsHttpError(err)
+	if !ok {
+		var rq string
+		if request != nil {
+			rq = string(request)
+		}
+		e = NewHttpError(0, err, duration, err.Error(), url, rq).(*HttpError)
+		err = e
+	}
+	rules.Apply(e)
+	return err
+}
+
+// ClassifyInterceptor classifies the errors of the next handlers by the error rules of the log config.
+// It is placed after LogInterceptor, so that the error logs contain the classified fields.
+func ClassifyInterceptor(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next(req)
+		if err == nil {
+			return res, err
+		}
+		c := getCall(req.Context())
+		if c.conf == nil || c.conf.Errors == nil {
+			return res, err
+		}
+		return res, Classify(c.conf.Errors, err, time.Since(start).Milliseconds(), req.URL.String(), c.body)
+	}
+}
+
+// classifyResponse returns err, or for a 4xx, 5xx response accepted by the status policy, a HttpError classified by the rules and the problem document of the body,
+// only to be logged. At most MaxBodySize bytes of the body are read, and put back for the caller.
+func classifyResponse(conf *LogConfig, req *http.Request, res *http.Response, err error, dur int64, body []byte) error {
+	if err != nil || res == nil || res.StatusCode < 400 {
+		return err
+	}
+	var max int
+	var problem *ProblemConfig
+	if conf != nil {
+		max, problem = conf.MaxBodySize, conf.Problem
+	}
+	b, _ := peekBody(res, max)
+	e := NewHttpError(res.StatusCode, nil, dur, fmt.Sprint("Response error with status code: ", res.StatusCode), req.URL.String(), string(body), string(b)).(*HttpError)
+	SetProblem(e, res.Header.Get("Content-Type"), b, problem)
+	if conf != nil && conf.Errors != nil {
+		conf.Errors.Apply(e)
+	}
+	return e
+}
+
+// ClassifiedFields adds ErrorType, ErrorCode, Service and Severity of err into the log fields,
+// by the keys of conf: error_type, error_code, service and severity (errorType, errorCode, service and severity by default).
+func ClassifiedFields(fields map[string]interface{}, conf *LogConfig, err error) {
+	e, ok := IsHttpError(err)
+	if !ok {
+		return
+	}
+	errorType, errorCode, service, severity := "errorType", "errorCode", "service", "severity"
+	if conf != nil {
+		if len(conf.ErrorType) > 0 {
+			errorType = conf.ErrorType
+		}
+		if len(conf.ErrorCode) > 0 {
+			errorCode = conf.ErrorCode
+		}
+		if len(conf.Service) > 0 {
+			service = conf.Service
+		}
+		if len(conf.Severity) > 0 {
+			severity = conf.Severity
+		}
+	}
+	if len(e.ErrorType) > 0 {
+		fields[errorType] = e.ErrorType
+	}
+	if len(e.ErrorCode) > 0 {
+		fields[errorCode] = e.ErrorCode
+	}
+	if len(e.Service) > 0 {
+		fields[service] = e.Service
+	}
+	if len(e.Severity) > 0 {
+		fields[severity] = e.Severity
+	}
+}
+
+func (r *ErrorRules) Apply(e *HttpError) {
+	if len(e.Service) == 0 {
+		e.Service = r.Service
+	}
+	var kind string
+	if e.StatusCode == 0 && e.RootError != nil {
+		kind = NetworkKind(e.RootError)
+	}
+	var body interface{}
+	parsed := false
+	for _, rule := range r.Rules {
+		if len(rule.Status) > 0 && (e.StatusCode == 0 || !MatchStatus(rule.Status, e.StatusCode)) {
+			continue
+		}
+		if len(rule.Network) > 0 && (len(kind) == 0 || (rule.Network != "*" && rule.Network != kind)) {
+			continue
+		}
+		var value string
+		if len(rule.Path) > 0 {
+			if !parsed {
+				body, parsed = parseBody(e.Response), true
+			}
+			v, ok := GetPath(body, rule.Path)
+			if !ok {
+				continue
+			}
+			value = toString(v)
+			if len(rule.Value) > 0 && rule.Value != value {
+				continue
+			}
+		}
+		if len(e.ErrorType) == 0 {
+			e.ErrorType = rule.ErrorType
+		}
+		if len(e.ErrorCode) == 0 {
+			if len(rule.ErrorCode) > 0 {
+				e.ErrorCode = rule.ErrorCode
+			} else if len(rule.Path) > 0 {
+				e.ErrorCode = value
+			}
+		}
+		if len(e.Severity) == 0 {
+			e.Severity = rule.Severity
+		}
+	}
+	if len(e.ErrorType) == 0 && len(kind) > 0 {
+		e.ErrorType = kind
+	}
+	if len(e.Severity) == 0 {
+		e.Severity = r.Severity
+	}
+}
+
+// MatchStatus matches a status code against a comma separated list of codes ("404"), classes ("5xx") and ranges ("400-499").
+func MatchStatus(pattern string, status int) bool {
+	for _, p := range strings.Split(pattern, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if len(p) == 0 {
+			continue
+		}
+		if p == "*" {
+			return true
+		}
+		if len(p) == 3 && strings.HasSuffix(p, "xx") {
+			if c, err := strconv.Atoi(p[:1]); err == nil && status/100 == c {
+				return true
+			}
+			continue
+		}
+		if i := strings.Index(p, "-"); i > 0 {
+			from, er1 := strconv.Atoi(strings.TrimSpace(p[:i]))
+			to, er2 := strconv.Atoi(strings.TrimSpace(p[i+1:]))
+			if er1 == nil && er2 == nil && status >= from && status <= to {
+				return true
+			}
+			continue
+		}
+		if c, err := strconv.Atoi(p); err == nil && c == status {
+			return true
+		}
+	}
+	return false
+}
+
+func NetworkKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return NetworkCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NetworkTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return NetworkTimeout
+		}
+		return NetworkDNS
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return NetworkTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return NetworkRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return NetworkReset
+	}
+	var recordErr tls.RecordHeaderError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) || strings.Contains(err.Error(), "tls: ") {
+		return NetworkTLS
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return NetworkEOF
+	}
+	return NetworkOther
+}
+
+// GetPath gets a value from a decoded JSON document by a dot path, such as "error.code" or "errors.0.code".
+func GetPath(v interface{}, path string) (interface{}, bool) {
+	for _, k := range strings.Split(path, ".") {
+		switch o := v.(type) {
+		case map[string]interface{}:
+			x, ok := o[k]
+			if !ok {
+				return nil, false
+			}
+			v = x
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(o) {
+				return nil, false
+			}
+			v = o[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func parseBody(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	// the response may be a dump of headers and body
+	if i := strings.Index(s, "\r\n\r\n"); i >= 0 {
+		if err := json.Unmarshal([]byte(s[i+4:]), &v); err == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func InitializeErrorRules(c *ErrorRules, service string) *ErrorRules {
+	if c == nil && len(service) == 0 {
+		return nil
+	}
+	var c2 ErrorRules
+	if c != nil {
+		c2 = *c
+	}
+	if len(c2.Service) == 0 {
+		c2.Service = service
+	}
+	return &c2
+}
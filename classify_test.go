@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchStatus(t *testing.T) {
+	tests := []struct {
+		pattern string
+		status  int
+		want    bool
+	}{
+		{"404", 404, true},
+		{"404", 405, false},
+		{"5xx", 503, true},
+		{"5XX", 500, true},
+		{"5xx", 404, false},
+		{"400-499", 429, true},
+		{"400-499", 500, false},
+		{"404, 5xx", 502, true},
+		{" 401 ,403", 403, true},
+		{"*", 200, true},
+		{"", 500, false},
+		{"abc", 500, false},
+	}
+	for _, tt := range tests {
+		if got := MatchStatus(tt.pattern, tt.status); got != tt.want {
+			t.Errorf("MatchStatus(%q, %d) = %v, want %v", tt.pattern, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestErrorRulesApply(t *testing.T) {
+	rules := &ErrorRules{
+		Service:  "billing",
+		Severity: "low",
+		Rules: []ErrorRule{
+			{Status: "404", ErrorType: "not_found"},
+			{Status: "5xx", Path: "error.code", Value: "LOCKED", ErrorType: "locked", Severity: "medium"},
+			{Status: "5xx", Path: "error.code", Severity: "high"},
+			{Network: "timeout", Severity: "high"},
+			{Network: "*", Severity: "medium"},
+		},
+	}
+	tests := []struct {
+		name     string
+		err      *HttpError
+		typ      string
+		code     string
+		severity string
+	}{
+		{"status", &HttpError{StatusCode: 404}, "not_found", "", "low"},
+		{"path value", &HttpError{StatusCode: 500, Response: `{"error":{"code":"LOCKED"}}`}, "locked", "LOCKED", "medium"},
+		{"path", &HttpError{StatusCode: 500, Response: `{"error":{"code":"E1"}}`}, "", "E1", "high"},
+		{"path in dump", &HttpError{StatusCode: 502, Response: "HTTP/1.1 502 Bad Gateway\r\nContent-Type: application/json\r\n\r\n{\"error\":{\"code\":\"E2\"}}"}, "", "E2", "high"},
+		{"no match", &HttpError{StatusCode: 400}, "", "", "low"},
+		{"timeout", &HttpError{RootError: context.DeadlineExceeded}, NetworkTimeout, "", "high"},
+		{"network", &HttpError{RootError: errors.New("boom")}, NetworkOther, "", "medium"},
+		{"preset", &HttpError{StatusCode: 404, ErrorType: "gone", Severity: "critical"}, "gone", "", "critical"},
+	}
+	for _, tt := range tests {
+		rules.Apply(tt.err)
+		if tt.err.Service != "billing" || tt.err.ErrorType != tt.typ || tt.err.ErrorCode != tt.code || tt.err.Severity != tt.severity {
+			t.Errorf("%s: got type=%q code=%q severity=%q service=%q", tt.name, tt.err.ErrorType, tt.err.ErrorCode, tt.err.Severity, tt.err.Service)
+		}
+	}
+}
+
+func TestClassifyNetworkError(t *testing.T) {
+	err := Classify(&ErrorRules{Service: "s"}, context.Canceled, 5, "http://x", []byte("rq"))
+	e, ok := IsHttpError(err)
+	if !ok || e.StatusCode != 0 || e.ErrorType != NetworkCanceled || e.Request != "rq" || !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected %#v", err)
+	}
+	if Classify(nil, context.Canceled, 0, "", nil) != context.Canceled {
+		t.Errorf("without rules, the error must be returned as is")
+	}
+}
+
+func TestClassifiedFieldsAreLogged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"code":"E42"}}`))
+	}))
+	defer srv.Close()
+	conf := &LogConfig{Status: StrictStatus(), Errors: &ErrorRules{Service: "billing", Rules: []ErrorRule{{Status: "5xx", Severity: "high", Path: "error.code"}}}}
+	var fields map[string]interface{}
+	logError := func(ctx context.Context, msg string, f map[string]interface{}) {
+		fields = f
+	}
+	err := Get(context.Background(), srv.Client(), srv.URL, nil, conf, logError)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if fields["severity"] != "high" || fields["errorCode"] != "E42" || fields["service"] != "billing" {
+		t.Errorf("classified fields are not logged: %v", fields)
+	}
+}
+
+func TestLenientResponsesAreClassified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"E7"}}`))
+	}))
+	defer srv.Close()
+	conf := &LogConfig{ErrorCode: "code", Severity: "level", Errors: &ErrorRules{Service: "billing", Rules: []ErrorRule{{Status: "4xx", Severity: "low", Path: "error.code"}}}}
+	var fields map[string]interface{}
+	logError := func(ctx context.Context, msg string, f map[string]interface{}) {
+		fields = f
+	}
+	res, err := DoAndLog(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, conf, logError)
+	if err != nil {
+		t.Fatalf("lenient policy must not return an error: %v", err)
+	}
+	defer res.Body.Close()
+	if fields["level"] != "low" || fields["code"] != "E7" || fields["service"] != "billing" {
+		t.Errorf("classified fields are not logged by the configured keys: %v", fields)
+	}
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != `{"error":{"code":"E7"}}` {
+		t.Errorf("the body must be kept for the caller: %q", b)
+	}
+}
@@ -1,18 +1,16 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/httputil"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -21,14 +19,19 @@ type ClientConfig struct {
 	Log      *LogConfig `yaml:"log" mapstructure:"log" json:"log,omitempty" gorm:"column:log" bson:"log,omitempty" dynamodbav:"log,omitempty" firestore:"log,omitempty"`
 }
 type ClientConf struct {
-	Config   Conf       `yaml:"config" mapstructure:"config" json:"config,omitempty" gorm:"column:config" bson:"config,omitempty" dynamodbav:"config,omitempty" firestore:"config,omitempty"`
-	Endpoint Endpoint   `yaml:"endpoint" mapstructure:"endpoint" json:"endpoint,omitempty" gorm:"column:endpoint" bson:"endpoint,omitempty" dynamodbav:"endpoint,omitempty" firestore:"endpoint,omitempty"`
-	Log      *LogConfig `yaml:"log" mapstructure:"log" json:"log,omitempty" gorm:"column:log" bson:"log,omitempty" dynamodbav:"log,omitempty" firestore:"log,omitempty"`
+	Config       Conf                  `yaml:"config" mapstructure:"config" json:"config,omitempty" gorm:"column:config" bson:"config,omitempty" dynamodbav:"config,omitempty" firestore:"config,omitempty"`
+	Endpoint     Endpoint              `yaml:"endpoint" mapstructure:"endpoint" json:"endpoint,omitempty" gorm:"column:endpoint" bson:"endpoint,omitempty" dynamodbav:"endpoint,omitempty" firestore:"endpoint,omitempty"`
+	Log          *LogConfig            `yaml:"log" mapstructure:"log" json:"log,omitempty" gorm:"column:log" bson:"log,omitempty" dynamodbav:"log,omitempty" firestore:"log,omitempty"`
+	Errors       *ErrorRules           `yaml:"errors" mapstructure:"errors" json:"errors,omitempty" gorm:"column:errors" bson:"errors,omitempty" dynamodbav:"errors,omitempty" firestore:"errors,omitempty"`
+	Interceptors []Interceptor         `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	Transports   []TransportMiddleware `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
 }
 type Endpoint struct {
-	Url      string  `yaml:"url" mapstructure:"url" json:"url,omitempty" gorm:"column:url" bson:"url,omitempty" dynamodbav:"url,omitempty" firestore:"url,omitempty"`
-	Username *string `yaml:"username" mapstructure:"username" json:"username,omitempty" gorm:"column:username" bson:"username,omitempty" dynamodbav:"username,omitempty" firestore:"username,omitempty"`
-	Password *string `yaml:"password" mapstructure:"password" json:"password,omitempty" gorm:"column:password" bson:"password,omitempty" dynamodbav:"password,omitempty" firestore:"password,omitempty"`
+	Name        string  `yaml:"name" mapstructure:"name" json:"name,omitempty" gorm:"column:name" bson:"name,omitempty" dynamodbav:"name,omitempty" firestore:"name,omitempty"`
+	Url         string  `yaml:"url" mapstructure:"url" json:"url,omitempty" gorm:"column:url" bson:"url,omitempty" dynamodbav:"url,omitempty" firestore:"url,omitempty"`
+	Username    *string `yaml:"username" mapstructure:"username" json:"username,omitempty" gorm:"column:username" bson:"username,omitempty" dynamodbav:"username,omitempty" firestore:"username,omitempty"`
+	Password    *string `yaml:"password" mapstructure:"password" json:"password,omitempty" gorm:"column:password" bson:"password,omitempty" dynamodbav:"password,omitempty" firestore:"password,omitempty"`
+	ContentType string  `yaml:"content_type" mapstructure:"content_type" json:"contentType,omitempty" gorm:"column:contenttype" bson:"contentType,omitempty" dynamodbav:"contentType,omitempty" firestore:"contentType,omitempty"`
 }
 type Config struct {
 	Insecure *bool          `yaml:"insecure" mapstructure:"insecure" json:"insecure,omitempty" gorm:"column:insecure" bson:"insecure,omitempty" dynamodbav:"insecure,omitempty" firestore:"insecure,omitempty"`
@@ -48,14 +51,42 @@ type Conf struct {
 	PEMFile  bool           `yaml:"pem_file" mapstructure:"pem_file" json:"pemFile,omitempty" gorm:"column:pemFile" bson:"pemFile,omitempty" dynamodbav:"pemFile,omitempty" firestore:"pemFile,omitempty"`
 }
 type LogConfig struct {
-	Separate       bool   `yaml:"separate" mapstructure:"separate" json:"separate,omitempty" gorm:"column:separate" bson:"separate,omitempty" dynamodbav:"separate,omitempty" firestore:"separate,omitempty"`
-	Log            bool   `yaml:"log" mapstructure:"log" json:"log,omitempty" gorm:"column:log" bson:"log,omitempty" dynamodbav:"log,omitempty" firestore:"log,omitempty"`
-	Duration       string `yaml:"duration" mapstructure:"duration" json:"duration,omitempty" gorm:"column:duration" bson:"duration,omitempty" dynamodbav:"duration,omitempty" firestore:"duration,omitempty"`
-	Size           string `yaml:"size" mapstructure:"size" json:"size,omitempty" gorm:"column:size" bson:"size,omitempty" dynamodbav:"size,omitempty" firestore:"size,omitempty"`
-	ResponseStatus string `yaml:"status" mapstructure:"status" json:"status,omitempty" gorm:"column:status" bson:"status,omitempty" dynamodbav:"status,omitempty" firestore:"status,omitempty"`
-	Request        string `yaml:"request" mapstructure:"request" json:"request,omitempty" gorm:"column:request" bson:"request,omitempty" dynamodbav:"request,omitempty" firestore:"request,omitempty"`
-	Response       string `yaml:"response" mapstructure:"response" json:"response,omitempty" gorm:"column:response" bson:"response,omitempty" dynamodbav:"response,omitempty" firestore:"response,omitempty"`
-	Error          string `yaml:"error" mapstructure:"error" json:"error,omitempty" gorm:"column:error" bson:"error,omitempty" dynamodbav:"error,omitempty" firestore:"error,omitempty"`
+	Separate             bool            `yaml:"separate" mapstructure:"separate" json:"separate,omitempty" gorm:"column:separate" bson:"separate,omitempty" dynamodbav:"separate,omitempty" firestore:"separate,omitempty"`
+	Log                  bool            `yaml:"log" mapstructure:"log" json:"log,omitempty" gorm:"column:log" bson:"log,omitempty" dynamodbav:"log,omitempty" firestore:"log,omitempty"`
+	Duration             string          `yaml:"duration" mapstructure:"duration" json:"duration,omitempty" gorm:"column:duration" bson:"duration,omitempty" dynamodbav:"duration,omitempty" firestore:"duration,omitempty"`
+	Size                 string          `yaml:"size" mapstructure:"size" json:"size,omitempty" gorm:"column:size" bson:"size,omitempty" dynamodbav:"size,omitempty" firestore:"size,omitempty"`
+	ResponseStatus       string          `yaml:"status" mapstructure:"status" json:"status,omitempty" gorm:"column:status" bson:"status,omitempty" dynamodbav:"status,omitempty" firestore:"status,omitempty"`
+	Request              string          `yaml:"request" mapstructure:"request" json:"request,omitempty" gorm:"column:request" bson:"request,omitempty" dynamodbav:"request,omitempty" firestore:"request,omitempty"`
+	Response             string          `yaml:"response" mapstructure:"response" json:"response,omitempty" gorm:"column:response" bson:"response,omitempty" dynamodbav:"response,omitempty" firestore:"response,omitempty"`
+	Error                string          `yaml:"error" mapstructure:"error" json:"error,omitempty" gorm:"column:error" bson:"error,omitempty" dynamodbav:"error,omitempty" firestore:"error,omitempty"`
+	Status               *StatusPolicy   `yaml:"status_policy" mapstructure:"status_policy" json:"statusPolicy,omitempty" gorm:"column:statuspolicy" bson:"statusPolicy,omitempty" dynamodbav:"statusPolicy,omitempty" firestore:"statusPolicy,omitempty"`
+	Problem              *ProblemConfig  `yaml:"problem" mapstructure:"problem" json:"problem,omitempty" gorm:"column:problem" bson:"problem,omitempty" dynamodbav:"problem,omitempty" firestore:"problem,omitempty"`
+	Redact               *RedactConfig   `yaml:"redact" mapstructure:"redact" json:"redact,omitempty" gorm:"column:redact" bson:"redact,omitempty" dynamodbav:"redact,omitempty" firestore:"redact,omitempty"`
+	MaxBodySize          int             `yaml:"max_body_size" mapstructure:"max_body_size" json:"maxBodySize,omitempty" gorm:"column:maxbodysize" bson:"maxBodySize,omitempty" dynamodbav:"maxBodySize,omitempty" firestore:"maxBodySize,omitempty"`
+	Binary               string          `yaml:"binary" mapstructure:"binary" json:"binary,omitempty" gorm:"column:binary" bson:"binary,omitempty" dynamodbav:"binary,omitempty" firestore:"binary,omitempty"`
+	JsonFormat           string          `yaml:"json_format" mapstructure:"json_format" json:"jsonFormat,omitempty" gorm:"column:jsonformat" bson:"jsonFormat,omitempty" dynamodbav:"jsonFormat,omitempty" firestore:"jsonFormat,omitempty"`
+	RequestHeaders       string          `yaml:"request_headers" mapstructure:"request_headers" json:"requestHeaders,omitempty" gorm:"column:requestheaders" bson:"requestHeaders,omitempty" dynamodbav:"requestHeaders,omitempty" firestore:"requestHeaders,omitempty"`
+	ResponseHeaders      string          `yaml:"response_headers" mapstructure:"response_headers" json:"responseHeaders,omitempty" gorm:"column:responseheaders" bson:"responseHeaders,omitempty" dynamodbav:"responseHeaders,omitempty" firestore:"responseHeaders,omitempty"`
+	AllowRequestHeaders  []string        `yaml:"allow_request_headers" mapstructure:"allow_request_headers" json:"allowRequestHeaders,omitempty" gorm:"column:allowrequestheaders" bson:"allowRequestHeaders,omitempty" dynamodbav:"allowRequestHeaders,omitempty" firestore:"allowRequestHeaders,omitempty"`
+	AllowResponseHeaders []string        `yaml:"allow_response_headers" mapstructure:"allow_response_headers" json:"allowResponseHeaders,omitempty" gorm:"column:allowresponseheaders" bson:"allowResponseHeaders,omitempty" dynamodbav:"allowResponseHeaders,omitempty" firestore:"allowResponseHeaders,omitempty"`
+	Levels               *LogLevels      `yaml:"levels" mapstructure:"levels" json:"levels,omitempty" gorm:"column:levels" bson:"levels,omitempty" dynamodbav:"levels,omitempty" firestore:"levels,omitempty"`
+	Sampling             *SamplingConfig `yaml:"sampling" mapstructure:"sampling" json:"sampling,omitempty" gorm:"column:sampling" bson:"sampling,omitempty" dynamodbav:"sampling,omitempty" firestore:"sampling,omitempty"`
+	Curl                 string          `yaml:"curl" mapstructure:"curl" json:"curl,omitempty" gorm:"column:curl" bson:"curl,omitempty" dynamodbav:"curl,omitempty" firestore:"curl,omitempty"`
+	TraceId              string          `yaml:"trace_id" mapstructure:"trace_id" json:"traceId,omitempty" gorm:"column:traceid" bson:"traceId,omitempty" dynamodbav:"traceId,omitempty" firestore:"traceId,omitempty"`
+	SpanId               string          `yaml:"span_id" mapstructure:"span_id" json:"spanId,omitempty" gorm:"column:spanid" bson:"spanId,omitempty" dynamodbav:"spanId,omitempty" firestore:"spanId,omitempty"`
+	ErrorType            string          `yaml:"error_type" mapstructure:"error_type" json:"errorType,omitempty" gorm:"column:errortype" bson:"errorType,omitempty" dynamodbav:"errorType,omitempty" firestore:"errorType,omitempty"`
+	ErrorCode            string          `yaml:"error_code" mapstructure:"error_code" json:"errorCode,omitempty" gorm:"column:errorcode" bson:"errorCode,omitempty" dynamodbav:"errorCode,omitempty" firestore:"errorCode,omitempty"`
+	Service              string          `yaml:"service" mapstructure:"service" json:"service,omitempty" gorm:"column:service" bson:"service,omitempty" dynamodbav:"service,omitempty" firestore:"service,omitempty"`
+	Severity             string          `yaml:"severity" mapstructure:"severity" json:"severity,omitempty" gorm:"column:severity" bson:"severity,omitempty" dynamodbav:"severity,omitempty" firestore:"severity,omitempty"`
+	RequestId            string          `yaml:"request_id" mapstructure:"request_id" json:"requestId,omitempty" gorm:"column:requestid" bson:"requestId,omitempty" dynamodbav:"requestId,omitempty" firestore:"requestId,omitempty"`
+	Errors               *ErrorRules     `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	TLS                  *Conf           `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	Metrics              Metrics         `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	Tracer               Tracer          `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	Interceptors         []Interceptor   `yaml:"-" mapstructure:"-" json:"-" gorm:"-" bson:"-" dynamodbav:"-" firestore:"-"`
+	dynamic              *DynamicLog
+	sampler              *Sampler
+	redactor             *Redactor
 }
 type Params struct {
 	Client   *http.Client
@@ -64,6 +95,7 @@ type Params struct {
 	Config   *LogConfig
 	LogError func(context.Context, string, map[string]interface{})
 	LogInfo  func(context.Context, string, map[string]interface{})
+	Codec    Codec
 }
 
 const (
@@ -77,6 +109,9 @@ const (
 // var conf3 LogConfig
 var sClient *http.Client
 
+// SetClient sets the default client of the process.
+//
+// Deprecated: SetClient is shared by all goroutines; use WithClient to set the default client per context, or pass the client to the helpers.
 func SetClient(c *http.Client) {
 	sClient = c
 }
@@ -106,6 +141,40 @@ func InitializeLog(c *LogConfig) *LogConfig {
 	}
 	c2.Request = c.Request
 	c2.Response = c.Response
+	c2.Status = c.Status
+	c2.Problem = c.Problem
+	c2.Levels = c.Levels
+	c2.Sampling = c.Sampling
+	c2.Curl = c.Curl
+	c2.TraceId = c.TraceId
+	c2.SpanId = c.SpanId
+	c2.RequestId = c.RequestId
+	c2.ErrorType = c.ErrorType
+	c2.ErrorCode = c.ErrorCode
+	c2.Service = c.Service
+	c2.Severity = c.Severity
+	c2.Errors = c.Errors
+	c2.TLS = c.TLS
+	c2.Metrics = c.Metrics
+	c2.Tracer = c.Tracer
+	c2.Interceptors = c.Interceptors
+	c2.dynamic = c.dynamic
+	c2.sampler = c.sampler
+	if c2.sampler == nil && c2.Sampling != nil {
+		c2.sampler = NewSampler(*c2.Sampling)
+	}
+	c2.Redact = c.Redact
+	c2.redactor = c.redactor
+	if c2.redactor == nil && c2.Redact != nil {
+		c2.redactor = NewRedactor(*c2.Redact)
+	}
+	c2.MaxBodySize = c.MaxBodySize
+	c2.Binary = c.Binary
+	c2.JsonFormat = c.JsonFormat
+	c2.RequestHeaders = c.RequestHeaders
+	c2.ResponseHeaders = c.ResponseHeaders
+	c2.AllowRequestHeaders = c.AllowRequestHeaders
+	c2.AllowResponseHeaders = c.AllowResponseHeaders
 	return &c2
 }
 func InitializeParams(config ClientConfig, opts ...func(context.Context, string, map[string]interface{})) (*Params, error) {
@@ -136,7 +205,14 @@ func InitParams(config ClientConf, opts ...func(context.Context, string, map[str
 	if len(opts) > 1 && opts[1] != nil {
 		logInfo = opts[1]
 	}
-	return &Params{Client: c, Url: config.Endpoint.Url, Header: header, Config: conf, LogError: logError, LogInfo: logInfo}, nil
+	var codec Codec
+	if len(config.Endpoint.ContentType) > 0 {
+		var ok bool
+		if codec, ok = LookupCodec(config.Endpoint.ContentType); !ok {
+			return nil, fmt.Errorf("no codec is registered for content type %s", config.Endpoint.ContentType)
+		}
+	}
+	return &Params{Client: c, Url: config.Endpoint.Url, Header: header, Config: conf, LogError: logError, LogInfo: logInfo, Codec: codec}, nil
 }
 func InitializeClient(config ClientConfig) (*http.Client, map[string]string, *LogConfig, error) {
 	e := config.Endpoint
@@ -153,6 +229,7 @@ func InitializeClient(config ClientConfig) (*http.Client, map[string]string, *Lo
 	}
 	header := CreateHeaderFromConfig(config.Endpoint)
 	l := InitializeLog(config.Log)
+	l.TLS = &conf
 	return c, header, l, nil
 }
 func InitClient(config ClientConf) (*http.Client, map[string]string, *LogConfig, error) {
@@ -162,7 +239,12 @@ func InitClient(config ClientConf) (*http.Client, map[string]string, *LogConfig,
 	}
 	header := CreateHeaderFromConf(config.Endpoint)
 	l := InitializeLog(config.Log)
-	return c, header, l, nil
+	l.Errors = InitializeErrorRules(config.Errors, config.Endpoint.Name)
+	l.TLS = &config.Config
+	if len(config.Interceptors) > 0 {
+		l.Interceptors = appendInterceptors(Interceptors(ResolveLog(l)), config.Interceptors...)
+	}
+	return Wrap(c, config.Transports...), header, l, nil
 }
 func NewClient(c Conf) (*http.Client, error) {
 	if len(c.CertFile) > 0 && len(c.KeyFile) > 0 {
@@ -255,49 +337,43 @@ func GetTLSClientConfig(clientCert tls.Certificate, options ...string) (*tls.Con
 	}
 	return c, nil
 }
-func DoJSON(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string) (*http.Response, error) {
-	if body != nil {
-		b := body
-		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(b))
-		if err != nil {
-			return nil, err
-		}
-		return AddHeaderAndDoJSON(client, req, headers)
-	} else {
-		req, err := http.NewRequestWithContext(ctx, method, url, nil)
-		if err != nil {
-			return nil, err
-		}
-		return AddHeaderAndDoJSON(client, req, headers)
+func DoJSON(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := newRequest(ctx, method, url, jsonBody(body), headers)
+	if err != nil {
+		return nil, err
 	}
+	return send(resolveClient(ctx, client), req)
 }
-func DoRequest(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string) (*http.Response, error) {
-	if body != nil {
-		b := body
-		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(b))
-		if err != nil {
-			return nil, err
-		}
-		return AddHeaderAndDo(client, req, headers)
-	} else {
-		req, err := http.NewRequestWithContext(ctx, method, url, nil)
-		if err != nil {
-			return nil, err
-		}
-		return AddHeaderAndDo(client, req, headers)
+func DoRequest(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := newRequest(ctx, method, url, rawBody(body), headers)
+	if err != nil {
+		return nil, err
 	}
+	return send(resolveClient(ctx, client), req)
 }
-func DoJSONWithClient(ctx context.Context, client *http.Client, method string, url string, obj interface{}, headers map[string]string, errorStatus int) (*json.Decoder, error) {
-	if client == nil {
-		client = sClient
+
+// newRequest creates the request with the body and its Content-Type; a streamed body is opened only if the request is created.
+func newRequest(ctx context.Context, method string, url string, body *Body, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	setBody(req, body)
+	var contentType string
+	if body != nil {
+		contentType = body.ContentType
 	}
+	addHeaders(req, headers, contentType)
+	return req, nil
+}
+func DoJSONWithClient(ctx context.Context, client Doer, method string, url string, obj interface{}, headers map[string]string, errorStatus int) (*json.Decoder, error) {
 	rq, err := Marshal(obj)
 	if err != nil {
 		return nil, err
 	}
 	return DoJSONAndDecode(ctx, client, method, url, rq, headers, errorStatus)
 }
-func DoJSONAndDecode(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string, errorStatus int) (*json.Decoder, error) {
+func DoJSONAndDecode(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string, errorStatus int) (*json.Decoder, error) {
 	start := time.Now()
 	response, er1 := DoJSON(ctx, client, method, url, body, headers)
 	end := time.Now()
@@ -306,138 +382,126 @@ func DoJSONAndDecode(ctx context.Context, client *http.Client, method string, ur
 		return nil, er1
 	}
 	if errorStatus < 0 {
-		res := json.NewDecoder(response.Body)
-		return res, nil
+		return bufferDecoder(response)
 	}
 	if response.StatusCode >= errorStatus {
 		res, er2 := io.ReadAll(response.Body)
+		response.Body.Close()
 		var rs string
 		if er2 == nil {
 			rs = string(res)
 		}
-		return nil, NewHttpError(response.StatusCode, nil, dur, fmt.Sprint("Response error with status code: ", response.StatusCode), url, string(body), rs)
+		er3 := NewHttpError(response.StatusCode, nil, dur, fmt.Sprint("Response error with status code: ", response.StatusCode), url, string(body), rs)
+		SetProblem(er3.(*HttpError), response.Header.Get("Content-Type"), res, nil)
+		return nil, er3
 	}
-	res := json.NewDecoder(response.Body)
-	return res, nil
+	return bufferDecoder(response)
 }
-func AddHeaderAndDoJSON(client *http.Client, req *http.Request, headers map[string]string) (*http.Response, error) {
-	if headers != nil {
-		for k, v := range headers {
-			req.Header.Add(k, v)
-		}
-	}
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	return resp, err
+func AddHeaderAndDoJSON(client Doer, req *http.Request, headers map[string]string) (*http.Response, error) {
+	addHeaders(req, headers, ContentTypeJSON)
+	return send(resolveClient(req.Context(), client), req)
+}
+func AddHeaderAndDo(client Doer, req *http.Request, headers map[string]string) (*http.Response, error) {
+	addHeaders(req, headers, "")
+	return send(resolveClient(req.Context(), client), req)
+}
+
+// send sends the request; the body of the response is tracked by the leak detector if it is enabled.
+func send(client Doer, req *http.Request) (*http.Response, error) {
+	res, err := client.Do(req)
+	return trackBody(res), err
 }
-func AddHeaderAndDo(client *http.Client, req *http.Request, headers map[string]string) (*http.Response, error) {
+
+// addHeaders adds the headers, then Content-Type and Accept (by the codec of the context) if they are not set by the headers, and the propagated headers.
+func addHeaders(req *http.Request, headers map[string]string, contentType string) {
 	if headers != nil {
 		for k, v := range headers {
 			req.Header.Add(k, v)
 		}
 	}
-	resp, err := client.Do(req)
-	return resp, err
+	if len(contentType) > 0 && len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c := GetCodec(req.Context()); c != nil && len(req.Header.Get("Accept")) == 0 {
+		req.Header.Set("Accept", c.ContentType())
+	}
+	Propagate(req.Context(), req.Header)
+	Inject(req.Context(), req.Header)
 }
-func DoGet(ctx context.Context, client *http.Client, url string, headers map[string]string) (*http.Response, error) {
+func DoGet(ctx context.Context, client Doer, url string, headers map[string]string) (*http.Response, error) {
 	return DoJSON(ctx, client, get, url, nil, headers)
 }
-func DoDelete(ctx context.Context, client *http.Client, url string, headers map[string]string) (*http.Response, error) {
+func DoDelete(ctx context.Context, client Doer, url string, headers map[string]string) (*http.Response, error) {
 	return DoJSON(ctx, client, delete, url, nil, headers)
 }
-func DoPost(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (*http.Response, error) {
+func DoPost(ctx context.Context, client Doer, url string, body []byte, headers map[string]string) (*http.Response, error) {
 	return DoJSON(ctx, client, post, url, body, headers)
 }
-func DoPut(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (*http.Response, error) {
+func DoPut(ctx context.Context, client Doer, url string, body []byte, headers map[string]string) (*http.Response, error) {
 	return DoJSON(ctx, client, put, url, body, headers)
 }
-func DoPatch(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (*http.Response, error) {
+func DoPatch(ctx context.Context, client Doer, url string, body []byte, headers map[string]string) (*http.Response, error) {
 	return DoJSON(ctx, client, patch, url, body, headers)
 }
-func GetDecoder(ctx context.Context, client *http.Client, url string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func GetDecoder(ctx context.Context, client Doer, url string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, get, url, nil, nil, conf, options...)
 }
-func GetDecoderWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func GetDecoderWithHeader(ctx context.Context, client Doer, url string, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, get, url, nil, headers, conf, options...)
 }
-func Get(ctx context.Context, client *http.Client, url string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+func Get(ctx context.Context, client Doer, url string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
 	return GetWithHeader(ctx, client, url, nil, result, conf, options...)
 }
-func GetWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
-	decoder, er1 := DoWithClient(ctx, client, get, url, nil, headers, conf, options...)
-	if er1 != nil {
-		return er1
-	}
-	er2 := decoder.Decode(result)
-	return er2
+func GetWithHeader(ctx context.Context, client Doer, url string, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	return doAndDecode(ctx, client, get, url, nil, headers, result, conf, options...)
 }
-func DeleteDecoder(ctx context.Context, client *http.Client, url string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func DeleteDecoder(ctx context.Context, client Doer, url string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, delete, url, nil, nil, conf, options...)
 }
-func DeleteDecoderWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func DeleteDecoderWithHeader(ctx context.Context, client Doer, url string, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, delete, url, nil, headers, conf, options...)
 }
-func Delete(ctx context.Context, client *http.Client, url string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+func Delete(ctx context.Context, client Doer, url string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
 	return DeleteWithHeader(ctx, client, url, nil, result, conf, options...)
 }
-func DeleteWithHeader(ctx context.Context, client *http.Client, url string, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
-	decoder, er1 := DoWithClient(ctx, client, delete, url, nil, headers, conf, options...)
-	if er1 != nil {
-		return er1
-	}
-	er2 := decoder.Decode(result)
-	return er2
+func DeleteWithHeader(ctx context.Context, client Doer, url string, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	return doAndDecode(ctx, client, delete, url, nil, headers, result, conf, options...)
 }
-func PostDecoder(ctx context.Context, client *http.Client, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PostDecoder(ctx context.Context, client Doer, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, post, url, obj, nil, conf, options...)
 }
-func PostDecoderWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PostDecoderWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, post, url, obj, headers, conf, options...)
 }
-func Post(ctx context.Context, client *http.Client, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+func Post(ctx context.Context, client Doer, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
 	return PostWithHeader(ctx, client, url, obj, nil, result, conf, options...)
 }
-func PostWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
-	decoder, er1 := DoWithClient(ctx, client, post, url, obj, headers, conf, options...)
-	if er1 != nil {
-		return er1
-	}
-	er2 := decoder.Decode(result)
-	return er2
+func PostWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	return doAndDecode(ctx, client, post, url, obj, headers, result, conf, options...)
 }
-func PutDecoder(ctx context.Context, client *http.Client, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PutDecoder(ctx context.Context, client Doer, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, put, url, obj, nil, conf, options...)
 }
-func PutDecoderWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PutDecoderWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, put, url, obj, headers, conf, options...)
 }
-func Put(ctx context.Context, client *http.Client, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+func Put(ctx context.Context, client Doer, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
 	return PutWithHeader(ctx, client, url, obj, nil, result, conf, options...)
 }
-func PutWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
-	decoder, er1 := DoWithClient(ctx, client, put, url, obj, headers, conf, options...)
-	if er1 != nil {
-		return er1
-	}
-	er2 := decoder.Decode(result)
-	return er2
+func PutWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	return doAndDecode(ctx, client, put, url, obj, headers, result, conf, options...)
 }
-func PatchDecoder(ctx context.Context, client *http.Client, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PatchDecoder(ctx context.Context, client Doer, url string, obj interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, patch, url, obj, nil, conf, options...)
 }
-func PatchDecoderWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+func PatchDecoderWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
 	return DoWithClient(ctx, client, patch, url, obj, headers, conf, options...)
 }
-func Patch(ctx context.Context, client *http.Client, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+func Patch(ctx context.Context, client Doer, url string, obj interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
 	return PatchWithHeader(ctx, client, url, obj, nil, result, conf, options...)
 }
-func PatchWithHeader(ctx context.Context, client *http.Client, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
-	decoder, er1 := DoWithClient(ctx, client, patch, url, obj, headers, conf, options...)
-	if er1 != nil {
-		return er1
-	}
-	er2 := decoder.Decode(result)
-	return er2
+func PatchWithHeader(ctx context.Context, client Doer, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	return doAndDecode(ctx, client, patch, url, obj, headers, result, conf, options...)
 }
 func Marshal(obj interface{}) ([]byte, error) {
 	b, ok := obj.([]byte)
@@ -462,543 +526,89 @@ func GetString(obj interface{}) (string, bool) {
 	}
 	return string(bs), true
 }
-func DoWithClient(ctx context.Context, client *http.Client, method string, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
-	if client == nil {
-		client = sClient
-	}
-	rq, err := Marshal(obj)
+func DoWithClient(ctx context.Context, client Doer, method string, url string, obj interface{}, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+	body, err := toBody(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
-	return DoAndBuildDecoder(ctx, client, method, url, rq, headers, conf, options...)
+	return buildDecoder(ctx, client, method, url, body, headers, conf, options)
 }
-func DoAndBuildDecoder(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
-	var logError func(context.Context, string, map[string]interface{})
-	var logInfo func(context.Context, string, map[string]interface{})
-	if len(options) > 0 {
-		logError = options[0]
-	}
-	if len(options) > 1 {
-		logInfo = options[1]
-	}
-	start := time.Now()
-	res, er1 := DoJSON(ctx, client, method, url, body, headers)
-	end := time.Now()
-	dur := end.Sub(start).Milliseconds()
-	if logError != nil && (er1 != nil || res.StatusCode >= 400) {
-		fs3 := make(map[string]interface{})
-		var c2 LogConfig
-		if conf != nil {
-			c2 = *conf
-		} else {
-			c2.Duration = "duration"
-			c2.Request = "request"
-			c2.Response = "response"
-			c2.ResponseStatus = "status"
-			c2.Error = "error"
-		}
-		if body != nil {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[c2.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(c2.Error) > 0 {
-				fs3[c2.Error] = er1.Error()
-			}
-			logError(ctx, method+" "+url, fs3)
-			return nil, er1
-		}
-		if len(c2.ResponseStatus) > 0 {
-			fs3[c2.ResponseStatus] = res.StatusCode
-		}
-		if len(c2.Size) > 0 && res.ContentLength > 0 {
-			fs3[c2.Size] = res.ContentLength
-		}
-		if len(c2.Response) > 0 {
-			dump, er3 := httputil.DumpResponse(res, true)
-			if er3 != nil {
-				if len(c2.Error) > 0 {
-					fs3[c2.Error] = er3.Error()
-				}
-				logError(ctx, method+" "+url, fs3)
-				return nil, er3
-			}
-			s := string(dump)
-			if len(c2.Size) > 0 {
-				fs3[c2.Size] = len(s)
-			}
-			if len(c2.Response) > 0 {
-				fs3[c2.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq, s)
-				return nil, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return json.NewDecoder(strings.NewReader(s)), nil
-		} else {
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return nil, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return json.NewDecoder(res.Body), nil
-		}
-	}
-	if conf != nil && conf.Log == true && logInfo != nil {
-		canRequest := false
-		if method != "GET" && method != "DELETE" && method != "OPTIONS" {
-			canRequest = true
-		}
-		if conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			fs1 := make(map[string]interface{}, 0)
-			rq := string(body)
-			if len(rq) > 0 {
-				fs1[conf.Request] = rq
-			}
-			logInfo(ctx, method+" "+url, fs1)
-		}
-		fs3 := make(map[string]interface{}, 0)
-		fs3[conf.Duration] = dur
-		if !conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[conf.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(conf.Error) > 0 {
-				fs3[conf.Error] = er1.Error()
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return nil, er1
-		}
-		if len(conf.ResponseStatus) > 0 {
-			fs3[conf.ResponseStatus] = res.StatusCode
-		}
-		if len(conf.Size) > 0 && res.ContentLength > 0 {
-			fs3[conf.Size] = res.ContentLength
-		}
-		if len(conf.Response) > 0 {
-			buf := new(bytes.Buffer)
-			_, er3 := buf.ReadFrom(res.Body)
-			if er3 != nil {
-				if len(conf.Error) > 0 {
-					fs3[conf.Error] = er3.Error()
-				}
-				logInfo(ctx, method+" "+url, fs3)
-				return nil, er3
-			}
-			s := buf.String()
-			if len(conf.Size) > 0 {
-				fs3[conf.Size] = len(s)
-			}
-			if len(conf.Response) > 0 {
-				fs3[conf.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return nil, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return json.NewDecoder(strings.NewReader(s)), nil
-		} else {
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return nil, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return json.NewDecoder(res.Body), nil
-		}
-	} else {
-		if er1 != nil {
-			return nil, er1
-		}
-		if res.StatusCode == 503 {
-			var rq string
-			if body != nil {
-				rq = string(body)
-			}
-			er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-			return nil, er2
+func DoAndBuildDecoder(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+	return buildDecoder(ctx, client, method, url, jsonBody(body), headers, conf, options)
+}
+func buildDecoder(ctx context.Context, client Doer, method string, url string, body *Body, headers map[string]string, conf *LogConfig, options []func(context.Context, string, map[string]interface{})) (*json.Decoder, error) {
+	res, err := execute(ctx, client, method, url, body, headers, conf, options, statusInterceptor)
+	if err != nil {
+		if res != nil {
+			DrainAndClose(res.Body)
 		}
-		return json.NewDecoder(res.Body), nil
+		return nil, err
 	}
+	return bufferDecoder(res)
 }
 
-func DoAndLog(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*http.Response, error) {
-	var logError func(context.Context, string, map[string]interface{})
-	var logInfo func(context.Context, string, map[string]interface{})
-	if len(options) > 0 {
-		logError = options[0]
-	}
-	if len(options) > 1 {
-		logInfo = options[1]
-	}
-	start := time.Now()
-	res, er1 := DoJSON(ctx, client, method, url, body, headers)
-	end := time.Now()
-	dur := end.Sub(start).Milliseconds()
-	if logError != nil && (er1 != nil || res.StatusCode >= 400) {
-		fs3 := make(map[string]interface{}, 0)
-		var c2 LogConfig
-		if conf != nil {
-			c2 = *conf
-		} else {
-			c2.Duration = "duration"
-			c2.Request = "request"
-			c2.Response = "response"
-			c2.ResponseStatus = "status"
-			c2.Error = "error"
-		}
-		if body != nil {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[c2.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(c2.Error) > 0 {
-				fs3[c2.Error] = er1.Error()
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, er1
-		}
-		if len(c2.ResponseStatus) > 0 {
-			fs3[c2.ResponseStatus] = res.StatusCode
-		}
-		if len(c2.Size) > 0 && res.ContentLength > 0 {
-			fs3[c2.Size] = res.ContentLength
-		}
-		if len(c2.Response) > 0 {
-			dump, er3 := httputil.DumpResponse(res, true)
-			if er3 != nil {
-				if len(c2.Error) > 0 {
-					fs3[c2.Error] = er3.Error()
-				}
-				logError(ctx, method+" "+url, fs3)
-				return res, er3
-			}
-			s := string(dump)
-			if len(c2.Size) > 0 {
-				fs3[c2.Size] = len(s)
-			}
-			if len(c2.Response) > 0 {
-				fs3[c2.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq, s)
-				return res, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, nil
-		} else {
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return res, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, nil
-		}
+// doAndDecode decodes the response into result, then drains and closes the body.
+func doAndDecode(ctx context.Context, client Doer, method string, url string, obj interface{}, headers map[string]string, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	body, err := toBody(ctx, obj)
+	if err != nil {
+		return err
 	}
-	if conf != nil && conf.Log == true && logInfo != nil {
-		canRequest := false
-		if method != "GET" && method != "DELETE" && method != "OPTIONS" {
-			canRequest = true
-		}
-		if conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			fs1 := make(map[string]interface{}, 0)
-			rq := string(body)
-			if len(rq) > 0 {
-				fs1[conf.Request] = rq
-			}
-			logInfo(ctx, method+" "+url, fs1)
-		}
-		fs3 := make(map[string]interface{}, 0)
-		fs3[conf.Duration] = dur
-		if !conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[conf.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(conf.Error) > 0 {
-				fs3[conf.Error] = er1.Error()
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, er1
-		}
-		if len(conf.ResponseStatus) > 0 {
-			fs3[conf.ResponseStatus] = res.StatusCode
-		}
-		if len(conf.Size) > 0 && res.ContentLength > 0 {
-			fs3[conf.Size] = res.ContentLength
-		}
-		if len(conf.Response) > 0 {
-			dump, er3 := httputil.DumpResponse(res, true)
-			if er3 != nil {
-				if len(conf.Error) > 0 {
-					fs3[conf.Error] = er3.Error()
-				}
-				logInfo(ctx, method+" "+url, fs3)
-				return res, er3
-			}
-			s := string(dump)
-			if len(conf.Size) > 0 {
-				fs3[conf.Size] = len(s)
-			}
-			if len(conf.Response) > 0 {
-				fs3[conf.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq, s)
-				return res, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, nil
-		} else {
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return res, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, nil
-		}
-	} else {
-		if er1 != nil {
-			return nil, er1
-		}
-		if res.StatusCode == 503 {
-			var rq string
-			if body != nil {
-				rq = string(body)
-			}
-			er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-			return res, er2
+	res, err := execute(ctx, client, method, url, body, headers, conf, options, statusInterceptor)
+	if err != nil {
+		if res != nil {
+			DrainAndClose(res.Body)
 		}
-		return res, nil
+		return err
 	}
+	return DecodeAndClose(res, result)
 }
 
-func DoAndLogCommon(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*http.Response, error) {
-	var logError func(context.Context, string, map[string]interface{})
-	var logInfo func(context.Context, string, map[string]interface{})
-	if len(options) > 0 {
-		logError = options[0]
-	}
-	if len(options) > 1 {
-		logInfo = options[1]
-	}
+// DoAndLog returns the response, which body must be closed by the caller (see DrainAndClose); DoResponse returns the body read and closed.
+func DoAndLog(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*http.Response, error) {
+	return execute(ctx, client, method, url, jsonBody(body), headers, conf, options)
+}
+func DoAndLogCommon(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*http.Response, error) {
+	return execute(ctx, client, method, url, rawBody(body), headers, conf, options)
+}
+
+// execute sends the request through the interceptors of conf (then the last interceptors),
+// and completes the error by classification, redaction and curl command, the metrics and the span.
+func execute(ctx context.Context, client Doer, method string, url string, b *Body, headers map[string]string, conf *LogConfig, options []func(context.Context, string, map[string]interface{}), last ...Interceptor) (*http.Response, error) {
 	start := time.Now()
-	res, er1 := DoRequest(ctx, client, method, url, body, headers)
-	end := time.Now()
-	dur := end.Sub(start).Milliseconds()
-	if logError != nil && (er1 != nil || res.StatusCode >= 400) {
-		fs3 := make(map[string]interface{}, 0)
-		var c2 LogConfig
-		if conf != nil {
-			c2 = *conf
-		} else {
-			c2.Duration = "duration"
-			c2.Request = "request"
-			c2.Response = "response"
-			c2.ResponseStatus = "status"
-			c2.Error = "error"
-		}
-		if body != nil {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[c2.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(c2.Error) > 0 {
-				fs3[c2.Error] = er1.Error()
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, er1
-		}
-		if len(c2.ResponseStatus) > 0 {
-			fs3[c2.ResponseStatus] = res.StatusCode
-		}
-		if len(c2.Size) > 0 && res.ContentLength > 0 {
-			fs3[c2.Size] = res.ContentLength
-		}
-		if len(c2.Response) > 0 {
-			dump, er3 := httputil.DumpResponse(res, true)
-			if er3 != nil {
-				if len(c2.Error) > 0 {
-					fs3[c2.Error] = er3.Error()
-				}
-				logError(ctx, method+" "+url, fs3)
-				return res, er3
-			}
-			s := string(dump)
-			if len(c2.Size) > 0 {
-				fs3[c2.Size] = len(s)
-			}
-			if len(c2.Response) > 0 {
-				fs3[c2.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq, s)
-				return res, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, nil
-		} else {
-			if res.StatusCode == 503 {
-				logError(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return res, er2
-			}
-			logError(ctx, method+" "+url, fs3)
-			return res, nil
-		}
-	}
-	if conf != nil && conf.Log == true && logInfo != nil {
-		canRequest := false
-		if method != "GET" && method != "DELETE" && method != "OPTIONS" {
-			canRequest = true
-		}
-		if conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			fs1 := make(map[string]interface{}, 0)
-			rq := string(body)
-			if len(rq) > 0 {
-				fs1[conf.Request] = rq
-			}
-			logInfo(ctx, method+" "+url, fs1)
-		}
-		fs3 := make(map[string]interface{}, 0)
-		fs3[conf.Duration] = dur
-		if !conf.Separate && len(conf.Request) > 0 && body != nil && canRequest {
-			rq := string(body)
-			if len(rq) > 0 {
-				fs3[conf.Request] = rq
-			}
-		}
-		if er1 != nil {
-			if len(conf.Error) > 0 {
-				fs3[conf.Error] = er1.Error()
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, er1
-		}
-		if len(conf.ResponseStatus) > 0 {
-			fs3[conf.ResponseStatus] = res.StatusCode
-		}
-		if len(conf.Size) > 0 && res.ContentLength > 0 {
-			fs3[conf.Size] = res.ContentLength
-		}
-		if len(conf.Response) > 0 {
-			dump, er3 := httputil.DumpResponse(res, true)
-			if er3 != nil {
-				if len(conf.Error) > 0 {
-					fs3[conf.Error] = er3.Error()
-				}
-				logInfo(ctx, method+" "+url, fs3)
-				return res, er3
-			}
-			s := string(dump)
-			if len(conf.Size) > 0 {
-				fs3[conf.Size] = len(s)
-			}
-			if len(conf.Response) > 0 {
-				fs3[conf.Response] = s
-			}
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq, s)
-				return res, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, nil
-		} else {
-			if res.StatusCode == 503 {
-				logInfo(ctx, method+" "+url, fs3)
-				var rq string
-				if body != nil {
-					rq = string(body)
-				}
-				er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-				return res, er2
-			}
-			logInfo(ctx, method+" "+url, fs3)
-			return res, nil
-		}
-	} else {
-		if er1 != nil {
-			return nil, er1
-		}
-		if res.StatusCode == 503 {
-			var rq string
-			if body != nil {
-				rq = string(body)
-			}
-			er2 := NewHttpError(http.StatusServiceUnavailable, er1, dur, "503 Service Unavailable", url, rq)
-			return res, er2
-		}
-		return res, nil
-	}
+	if b != nil && b.err != nil {
+		return nil, b.err
+	}
+	body := b.logBytes()
+	client = resolveClient(ctx, client)
+	conf = ResolveLog(conf)
+	ctx, end := StartSpan(EnsureRequestId(ctx), conf, method, url)
+	c2, opts := SampleOptions(conf, url, LogRequestId(conf, LogTrace(conf, LogOptions(conf, options))))
+	client, opts, rec := LogHeaders(conf, client, opts)
+	opts = LogCurl(conf, client, rec, method, url, b, headers, opts)
+	var res *http.Response
+	var latency time.Duration
+	ctx2 := withCall(ctx, c2, body, opts)
+	req, err := newRequest(ctx2, method, url, b, headers)
+	if err == nil {
+		res, err = Chain(func(req *http.Request) (*http.Response, error) {
+			getCall(req.Context()).header = req.Header.Clone()
+			res, err := send(client, req)
+			latency = time.Since(start)
+			return res, err
+		}, appendInterceptors(Interceptors(conf), last...)...)(req)
+	}
+	if latency == 0 {
+		// the request is not sent, or the response is returned by an interceptor
+		latency = time.Since(start)
+	}
+	err = completeError(conf, err, start, method, url, b, curlHeader(rec, getCall(ctx2).header, headers), client)
+	Observe(ctx, conf, rec, latency, method, url, body, res, err)
+	end(rec, err)
+	return res, err
 }
 
 type HttpError struct {
+	Method       string
 	StatusCode   int
 	ErrorMessage string
 	RootError    error
@@ -1010,6 +620,8 @@ type HttpError struct {
 	ErrorCode    string
 	Service      string
 	Severity     string
+	Details      map[string]interface{}
+	curl         string
 }
 
 func NewHttpError(statusCode int, rootError error, duration int64, opts ...string) error {
@@ -1055,15 +667,18 @@ func (e *HttpError) GetRootError() string {
 	return ""
 }
 func IsHttpError(err error) (*HttpError, bool) {
-	httpErr, ok := err.(*HttpError)
-	if ok {
-		return httpErr, ok
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
 	} else {
-		return nil, ok
+		return nil, false
 	}
 }
 func MakeMap(err *HttpError, prefix string) map[string]interface{} {
 	mp := make(map[string]interface{})
+	if len(err.Method) > 0 {
+		mp[prefix+"Method"] = err.Method
+	}
 	mp[prefix+"Duration"] = err.Duration
 	mp[prefix+"Status"] = err.StatusCode
 	if len(err.Request) > 0 {
@@ -1078,8 +693,8 @@ func MakeMap(err *HttpError, prefix string) map[string]interface{} {
 	if len(err.ErrorMessage) > 0 {
 		mp[prefix+"Error"] = err.ErrorMessage
 	}
-	if err.RootError != nil && err.Error() != err.ErrorMessage {
-		mp[prefix+"RootError"] = err.Error()
+	if err.RootError != nil {
+		mp[prefix+"RootError"] = err.RootError.Error()
 	}
 	if len(err.ErrorType) > 0 {
 		mp[prefix+"ErrorType"] = err.ErrorType
@@ -1093,5 +708,8 @@ func MakeMap(err *HttpError, prefix string) map[string]interface{} {
 	if len(err.Severity) > 0 {
 		mp[prefix+"Severity"] = err.Severity
 	}
+	if len(err.Details) > 0 {
+		mp[prefix+"Details"] = err.Details
+	}
 	return mp
 }
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxDrainSize is the max number of bytes read from the rest of a body before closing it, so that the connection can be reused.
+// A longer body is closed without being drained, then the connection is closed.
+var MaxDrainSize int64 = 256 << 10
+
+// DrainAndClose reads the rest of the body, up to MaxDrainSize bytes, then closes it.
+func DrainAndClose(body io.ReadCloser) error {
+	if body == nil {
+		return nil
+	}
+	io.CopyN(io.Discard, body, MaxDrainSize)
+	return body.Close()
+}
+
+// DecodeAndClose decodes the body of the response into result (if result is not nil), then drains and closes the body.
+// The decoder is the codec of the response Content-Type (see LookupCodec), JSON by default. An empty body is not an error.
+func DecodeAndClose(res *http.Response, result interface{}) error {
+	defer DrainAndClose(res.Body)
+	if result == nil {
+		return nil
+	}
+	return decodeBody(res, result)
+}
+
+// bufferDecoder reads and closes the body, and returns a decoder of the read bytes, so that the caller of the decoder APIs does not need to close the body.
+func bufferDecoder(res *http.Response) (*json.Decoder, error) {
+	if res.Body == nil {
+		return json.NewDecoder(bytes.NewReader(nil)), nil
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return json.NewDecoder(bytes.NewReader(b)), nil
+}
+
+// LeakDetector tracks the response bodies returned by the helpers; a body which is garbage collected without being closed is reported with the stack of the call site.
+// It is intended for debug and test, because every response records a stack.
+type LeakDetector struct {
+	Report func(stack string)
+	seq    atomic.Uint64
+	open   sync.Map
+}
+
+var leakDetector atomic.Pointer[LeakDetector]
+
+// EnableLeakDetector starts tracking the response bodies. If report is nil, the leaks are printed to stderr.
+func EnableLeakDetector(report func(stack string)) *LeakDetector {
+	d := &LeakDetector{Report: report}
+	if d.Report == nil {
+		d.Report = func(stack string) {
+			fmt.Fprintln(os.Stderr, "client: response body is not closed, created at:\n"+stack)
+		}
+	}
+	leakDetector.Store(d)
+	return d
+}
+func DisableLeakDetector() {
+	leakDetector.Store(nil)
+}
+
+// Open returns the stacks of the call sites of the bodies not closed yet.
+func (d *LeakDetector) Open() []string {
+	var stacks []string
+	d.open.Range(func(_, stack interface{}) bool {
+		stacks = append(stacks, stack.(string))
+		return true
+	})
+	return stacks
+}
+
+// Check returns an error with the stacks of the bodies not closed yet, to be called at the end of a test.
+func (d *LeakDetector) Check() error {
+	stacks := d.Open()
+	if len(stacks) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d response bodies are not closed:\n%s", len(stacks), strings.Join(stacks, "\n"))
+}
+
+// trackedBody is not referenced by the detector, so that the finalizer runs when the caller drops the response.
+type trackedBody struct {
+	io.ReadCloser
+	detector *LeakDetector
+	id       uint64
+}
+
+func (b *trackedBody) Close() error {
+	b.detector.remove(b.id)
+	return b.ReadCloser.Close()
+}
+func (d *LeakDetector) remove(id uint64) (string, bool) {
+	stack, ok := d.open.LoadAndDelete(id)
+	if !ok {
+		return "", false
+	}
+	return stack.(string), true
+}
+
+// trackBody returns a copy of the response with the tracked body, if the leak detector is enabled.
+// The response is copied, because the transport keeps the original one until the body is closed.
+func trackBody(res *http.Response) *http.Response {
+	d := leakDetector.Load()
+	if d == nil || res == nil || res.Body == nil || res.Body == http.NoBody {
+		return res
+	}
+	b := &trackedBody{ReadCloser: res.Body, detector: d, id: d.seq.Add(1)}
+	d.open.Store(b.id, string(debug.Stack()))
+	runtime.SetFinalizer(b, finalizeBody)
+	r2 := *res
+	r2.Body = b
+	return &r2
+}
+func finalizeBody(b *trackedBody) {
+	if stack, open := b.detector.remove(b.id); open {
+		b.detector.Report(stack)
+	}
+}
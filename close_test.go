@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLeakDetector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	var reports []string
+	d := EnableLeakDetector(func(stack string) { reports = append(reports, stack) })
+	defer DisableLeakDetector()
+
+	var result map[string]string
+	if err := Get(context.Background(), srv.Client(), srv.URL, &result, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Check(); err != nil {
+		t.Fatalf("the bodies decoded by the helpers must be closed, got %v", err)
+	}
+	res := leak(t, srv.URL)
+	if err := d.Check(); err == nil || !strings.Contains(err.Error(), "1 response bodies") || !strings.Contains(err.Error(), "leak") {
+		t.Fatalf("the body returned to the caller must be open with the stack of the call site, got %v", err)
+	}
+
+	// the finalizer is called as the garbage collector would, so that the report does not depend on the collection
+	b, ok := res.Body.(*trackedBody)
+	if !ok {
+		t.Fatalf("the body must be tracked, got %T", res.Body)
+	}
+	finalizeBody(b)
+	if len(reports) != 1 || !strings.Contains(reports[0], "leak") {
+		t.Fatalf("the body collected without being closed must be reported with the call site, got %v", reports)
+	}
+	if err := d.Check(); err != nil {
+		t.Errorf("a reported body must not be open any more, got %v", err)
+	}
+	DrainAndClose(res.Body)
+	finalizeBody(b)
+	if len(reports) != 1 {
+		t.Errorf("a closed body must not be reported, got %d reports", len(reports))
+	}
+}
+
+func leak(t *testing.T, url string) *http.Response {
+	res, err := DoAndLog(context.Background(), http.DefaultClient, http.MethodGet, url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const ContentTypeXML = "application/xml"
+
+// Codec encodes the request body and decodes the response body of a media type.
+// JSON and XML are registered by default; github.com/core-go/client/codec/yaml, codec/cbor and codec/msgpack register YAML, CBOR and MessagePack when imported.
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string {
+	return ContentTypeXML
+}
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{
+		ContentTypeJSON: JSONCodec{},
+		ContentTypeXML:  XMLCodec{},
+		"text/xml":      XMLCodec{},
+	}
+)
+
+// RegisterCodec registers the codec for its content type and the other media types, such as "application/x-yaml" and "text/yaml".
+func RegisterCodec(c Codec, mediaTypes ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[strings.ToLower(c.ContentType())] = c
+	for _, t := range mediaTypes {
+		codecs[strings.ToLower(t)] = c
+	}
+}
+
+// LookupCodec returns the codec of the media type of contentType; the parameters, such as charset, are ignored.
+// A structured syntax suffix, such as "application/problem+json" or "application/atom+xml", falls back to the codec of the suffix.
+func LookupCodec(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	if c, ok := codecs[mediaType]; ok {
+		return c, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		if c, ok := codecs["application/"+mediaType[i+1:]]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+type codecKey struct{}
+
+// WithCodec sets the codec of the calls of the context: the request body is encoded by the codec, and Accept is its content type.
+// The Client sets its own codec (Client.Codec) if the context has none.
+func WithCodec(ctx context.Context, c Codec) context.Context {
+	return context.WithValue(ctx, codecKey{}, c)
+}
+func GetCodec(ctx context.Context) Codec {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(codecKey{}).(Codec)
+	return c
+}
+
+// responseCodec returns the codec of the Content-Type of the response, or nil if it is JSON, missing or not registered, then the body is decoded as JSON.
+func responseCodec(res *http.Response) Codec {
+	c, ok := LookupCodec(res.Header.Get("Content-Type"))
+	if !ok {
+		return nil
+	}
+	if _, isJSON := c.(JSONCodec); isJSON {
+		return nil
+	}
+	return c
+}
+
+// decodeBody decodes the body by the codec of the response Content-Type; an empty body is not decoded.
+func decodeBody(res *http.Response, result interface{}) error {
+	c := responseCodec(res)
+	if c == nil {
+		err := json.NewDecoder(res.Body).Decode(result)
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	return c.Unmarshal(b, result)
+}
+
+// encodeBody encodes obj by the codec; []byte and string are sent as is, with the content type of the codec.
+func encodeBody(c Codec, obj interface{}) (*Body, error) {
+	var data []byte
+	switch v := obj.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = c.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	return &Body{ContentType: c.ContentType(), data: data, Length: int64(len(data))}, nil
+}
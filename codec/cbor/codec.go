@@ -0,0 +1,25 @@
+package cbor
+
+import (
+	"github.com/core-go/client"
+	"github.com/fxamacker/cbor/v2"
+)
+
+const ContentType = "application/cbor"
+
+// Codec implements client.Codec with CBOR (RFC 8949). It is registered for application/cbor when the package is imported.
+type Codec struct{}
+
+func init() {
+	client.RegisterCodec(Codec{})
+}
+
+func (Codec) ContentType() string {
+	return ContentType
+}
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
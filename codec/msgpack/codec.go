@@ -0,0 +1,25 @@
+package msgpack
+
+import (
+	"github.com/core-go/client"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const ContentType = "application/msgpack"
+
+// Codec implements client.Codec with MessagePack. It is registered for application/msgpack, application/x-msgpack and application/vnd.msgpack when the package is imported.
+type Codec struct{}
+
+func init() {
+	client.RegisterCodec(Codec{}, "application/x-msgpack", "application/vnd.msgpack")
+}
+
+func (Codec) ContentType() string {
+	return ContentType
+}
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
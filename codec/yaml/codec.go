@@ -0,0 +1,25 @@
+package yaml
+
+import (
+	"github.com/core-go/client"
+	"gopkg.in/yaml.v3"
+)
+
+const ContentType = "application/yaml"
+
+// Codec implements client.Codec with YAML. It is registered for application/yaml, application/x-yaml and text/yaml when the package is imported.
+type Codec struct{}
+
+func init() {
+	client.RegisterCodec(Codec{}, "application/x-yaml", "text/yaml", "text/x-yaml")
+}
+
+func (Codec) ContentType() string {
+	return ContentType
+}
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+)
+
+type textCodec struct {
+	JSONCodec
+}
+
+func (textCodec) ContentType() string {
+	return "Application/X-Test"
+}
+
+func TestLookupCodec(t *testing.T) {
+	RegisterCodec(textCodec{}, "Text/X-Test")
+	tests := []struct {
+		contentType string
+		want        Codec
+	}{
+		{"application/json", JSONCodec{}},
+		{"application/problem+json; charset=utf-8", JSONCodec{}},
+		{"text/xml", XMLCodec{}},
+		{"application/atom+xml", XMLCodec{}},
+		{"application/x-test", textCodec{}},
+		{"APPLICATION/X-TEST", textCodec{}},
+		{"text/x-test", textCodec{}},
+		{"text/plain", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		c, ok := LookupCodec(tt.contentType)
+		if ok != (tt.want != nil) || c != tt.want {
+			t.Errorf("LookupCodec(%q) = %v, %v, want %v", tt.contentType, c, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Curl returns an equivalent curl command of the failed request, with the redacted headers and body.
+func (e *HttpError) Curl() string {
+	if len(e.curl) > 0 {
+		return e.curl
+	}
+	if len(e.Url) == 0 {
+		return ""
+	}
+	return BuildCurl(e.Method, e.Url, nil, e.Request)
+}
+
+// BuildCurl builds a curl command. The arguments are quoted for a POSIX shell.
+func BuildCurl(method string, url string, header http.Header, body string, flags ...string) string {
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	var b strings.Builder
+	b.WriteString("curl -X ")
+	b.WriteString(method)
+	b.WriteString(" ")
+	b.WriteString(ShellQuote(url))
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			b.WriteString(" -H ")
+			b.WriteString(ShellQuote(k + ": " + v))
+		}
+	}
+	if len(body) > 0 && body != "null" {
+		b.WriteString(" --data-raw ")
+		b.WriteString(ShellQuote(body))
+	}
+	for _, f := range flags {
+		b.WriteString(" ")
+		b.WriteString(f)
+	}
+	return b.String()
+}
+
+// TLSFlags returns --cert, --key and -k from the config of the client. If c is nil, -k is taken from the transport of the client.
+func TLSFlags(c *Conf, client Doer) []string {
+	var flags []string
+	insecure := false
+	if c != nil {
+		if len(c.CertFile) > 0 && len(c.KeyFile) > 0 {
+			flags = append(flags, "--cert "+ShellQuote(c.CertFile), "--key "+ShellQuote(c.KeyFile))
+			insecure = true
+		} else if c.Insecure != nil {
+			insecure = *c.Insecure
+		}
+	} else if c, ok := client.(*http.Client); ok && c != nil {
+		if t, ok := c.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+			insecure = t.TLSClientConfig.InsecureSkipVerify
+		}
+	}
+	if insecure {
+		flags = append(flags, "-k")
+	}
+	return flags
+}
+
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// LogCurl wraps a log function to add the curl command into the curl field of the error logs.
+func LogCurl(conf *LogConfig, client Doer, rec *HeaderRecorder, method string, url string, b *Body, headers map[string]string, options []func(context.Context, string, map[string]interface{})) []func(context.Context, string, map[string]interface{}) {
+	if conf == nil || len(conf.Curl) == 0 {
+		return options
+	}
+	status, errorKey := "status", "error"
+	if len(conf.ResponseStatus) > 0 {
+		status = conf.ResponseStatus
+	}
+	if len(conf.Error) > 0 {
+		errorKey = conf.Error
+	}
+	r := redactorOf(conf)
+	return wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return func(ctx context.Context, msg string, fields map[string]interface{}) {
+			code, _ := fields[status].(int)
+			if _, ok := fields[errorKey]; ok || code >= 400 {
+				var rq string
+				flags := TLSFlags(conf.TLS, client)
+				if b.Streamed() {
+					flags = append(b.curlFlags(r), flags...)
+				} else if body := b.logBytes(); body != nil {
+					rq = r.Body(string(body))
+				}
+				fields[conf.Curl] = BuildCurl(method, r.Url(url), curlHeaders(r, curlHeader(rec, getCall(ctx).header, headers), b), rq, flags...)
+			}
+			log(ctx, msg, fields)
+		}
+	})
+}
+
+// curlHeader returns the headers of the request recorded by the transport, or else the headers of the request sent after the interceptors
+// (with Content-Type, Accept, the propagated and trace headers), or else the headers passed to the helpers if the request is not sent.
+func curlHeader(rec *HeaderRecorder, sent http.Header, headers map[string]string) http.Header {
+	if h := rec.Request(); h != nil {
+		return h
+	}
+	if sent != nil {
+		return sent
+	}
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h.Add(k, v)
+	}
+	return h
+}
+
+// curlHeaders returns the redacted headers of the curl command. The Content-Type of a multipart body is removed, because curl sets it with its own boundary.
+func curlHeaders(r *Redactor, header http.Header, b *Body) http.Header {
+	h := http.Header(r.Headers(header))
+	if b.Streamed() && strings.HasPrefix(b.ContentType, ContentTypeMultipart) {
+		h.Del("Content-Type")
+	}
+	return h
+}
+
+// curlFlags returns the -F flags of a multipart body, with the redacted field values; the files are referred by their names.
+// A reader body has no flags, because its content is not kept.
+func (b *Body) curlFlags(r *Redactor) []string {
+	var flags []string
+	for _, k := range sortedKeys(b.fields) {
+		for _, v := range b.fields[k] {
+			flags = append(flags, "--form-string "+ShellQuote(k+"="+r.Field(k, v)))
+		}
+	}
+	for _, f := range b.files {
+		contentType := f.ContentType
+		if len(contentType) == 0 {
+			contentType = ContentTypeBinary
+		}
+		flags = append(flags, "-F "+ShellQuote(f.Field+"=@"+f.FileName+";type="+contentType))
+	}
+	return flags
+}
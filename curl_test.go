@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCurlOfStreamedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	conf := &LogConfig{Curl: "curl", Redact: &RedactConfig{Fields: []string{"password"}}}
+	c := &Client{Client: srv.Client(), Url: srv.URL, Config: conf}
+	fields := url.Values{"user": {"tom"}, "password": {"secret"}}
+	tests := []struct {
+		name    string
+		body    *Body
+		want    []string
+		notWant []string
+	}{
+		{"multipart", MultipartBody(fields, FilePart{Field: "doc", FileName: "a.txt", ContentType: "text/plain", Reader: strings.NewReader("abc")}),
+			[]string{`--form-string 'password=***'`, `--form-string 'user=tom'`, `-F 'doc=@a.txt;type=text/plain'`},
+			[]string{"--data-raw", "secret", "Content-Type"}},
+		{"reader", ReaderBody(strings.NewReader("abc"), "text/plain", 3),
+			[]string{"Content-Type: text/plain"},
+			[]string{"--data-raw", "-F"}},
+	}
+	for _, tt := range tests {
+		_, err := c.R(context.Background()).Method(http.MethodPost).Body(tt.body).Expect(http.StatusOK).Do()
+		e, ok := IsHttpError(err)
+		if !ok {
+			t.Fatalf("%s: expected HttpError, got %v", tt.name, err)
+		}
+		curl := e.Curl()
+		for _, s := range tt.want {
+			if !strings.Contains(curl, s) {
+				t.Errorf("%s: %s does not contain %s", tt.name, curl, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(curl, s) {
+				t.Errorf("%s: %s must not contain %s", tt.name, curl, s)
+			}
+		}
+	}
+}
+
+func TestNilBody(t *testing.T) {
+	var b *Body
+	if r := (&Client{}).R(context.Background()).Body(b); r.err != nil || r.body != nil {
+		t.Errorf("a nil body must be no body")
+	}
+	if body, err := toBody(context.Background(), b); body != nil || err != nil {
+		t.Errorf("a typed nil body must be no body, got %v, %v", body, err)
+	}
+}
+
+func TestCurlHasTheHeadersOfTheSentRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	signer := func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Signature", "abc")
+			return next(req)
+		}
+	}
+	// no curl field, no header logs, no metrics and no tracer: the headers are not recorded by the transport
+	conf := &LogConfig{Status: StrictStatus(), Interceptors: []Interceptor{signer}}
+	ctx := WithHeaders(context.Background(), http.Header{"X-Tenant-Id": {"t1"}})
+	var result map[string]interface{}
+	err := PostWithHeader(ctx, srv.Client(), srv.URL, map[string]string{"id": "1"}, map[string]string{"Authorization": "Bearer token"}, &result, conf)
+	e, ok := IsHttpError(err)
+	if !ok {
+		t.Fatalf("expected HttpError, got %v", err)
+	}
+	curl := e.Curl()
+	for _, s := range []string{`'Content-Type: application/json'`, `'Authorization: ***'`, `'X-Tenant-Id: t1'`, `'X-Signature: abc'`, `'X-Request-Id: `} {
+		if !strings.Contains(curl, s) {
+			t.Errorf("%s does not contain %s", curl, s)
+		}
+	}
+	if strings.Contains(curl, "token") {
+		t.Errorf("%s must not contain the authorization", curl)
+	}
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Doer sends an http request, such as *http.Client, a mock or a decorated client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type clientKey struct{}
+
+// WithClient sets the default client of the helpers into the context; it is used when the client passed to the helpers is nil.
+func WithClient(ctx context.Context, client Doer) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// GetClient returns the client of the context, or the client set by SetClient, or http.DefaultClient.
+func GetClient(ctx context.Context) Doer {
+	if ctx != nil {
+		if c, ok := ctx.Value(clientKey{}).(Doer); ok && !isNilDoer(c) {
+			return c
+		}
+	}
+	if sClient != nil {
+		return sClient
+	}
+	return http.DefaultClient
+}
+
+// resolveClient returns client, or the default client of the context if client is nil.
+func resolveClient(ctx context.Context, client Doer) Doer {
+	if isNilDoer(client) {
+		return GetClient(ctx)
+	}
+	return client
+}
+func isNilDoer(client Doer) bool {
+	if client == nil {
+		return true
+	}
+	c, ok := client.(*http.Client)
+	return ok && c == nil
+}
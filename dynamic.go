@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DynamicLog holds a LogConfig which can be swapped at runtime. The helpers resolve the LogConfig returned by Config() for every call,
+// so that all Params sharing it pick up the new config without restart.
+type DynamicLog struct {
+	value  atomic.Pointer[LogConfig]
+	handle *LogConfig
+}
+
+func NewDynamicLog(c *LogConfig) *DynamicLog {
+	d := &DynamicLog{}
+	d.handle = &LogConfig{dynamic: d}
+	d.Store(c)
+	return d
+}
+
+// Config returns the LogConfig to be passed to Params or the helpers.
+func (d *DynamicLog) Config() *LogConfig {
+	return d.handle
+}
+func (d *DynamicLog) Load() *LogConfig {
+	return d.value.Load()
+}
+
+// Store replaces the config. The config is initialized by InitializeLog; the error rules, TLS config, metrics, tracer and interceptors are kept if c has none.
+// The sampler (and its dedup state) is kept if the sampling config is unchanged, and so is the redactor if the redact config is unchanged.
+func (d *DynamicLog) Store(c *LogConfig) {
+	c2 := InitializeLog(c)
+	c2.dynamic = nil
+	c2.sampler = nil
+	c2.redactor = nil
+	old := d.value.Load()
+	if c2.Redact != nil {
+		// the redactor is bound to the config, and rebuilt only when the redact config changes
+		if old != nil && old.redactor != nil && old.Redact != nil && reflect.DeepEqual(*old.Redact, *c2.Redact) {
+			c2.redactor = old.redactor
+		} else {
+			c2.redactor = NewRedactor(*c2.Redact)
+		}
+	}
+	if c2.Sampling != nil {
+		// the sampler is bound to the config, to keep the dedup state when the sampling config is unchanged
+		if old != nil && old.sampler != nil && reflect.DeepEqual(*old.Sampling, *c2.Sampling) {
+			c2.sampler = old.sampler
+		} else {
+			c2.sampler = NewSampler(*c2.Sampling)
+		}
+	}
+	if old != nil && old.sampler != nil && old.sampler != c2.sampler {
+		old.sampler.Flush()
+	}
+	if old != nil {
+		if c2.Errors == nil {
+			c2.Errors = old.Errors
+		}
+		if c2.TLS == nil {
+			c2.TLS = old.TLS
+		}
+		if c2.Metrics == nil {
+			c2.Metrics = old.Metrics
+		}
+		if c2.Tracer == nil {
+			c2.Tracer = old.Tracer
+		}
+		if c2.Interceptors == nil {
+			c2.Interceptors = old.Interceptors
+		}
+	}
+	d.value.Store(c2)
+}
+
+// ResolveLog returns the current config if conf is bound to a DynamicLog, else conf itself.
+// The error rules, TLS config and interceptors set on the bound conf (by InitClient or Params.Use) override the ones of the current config,
+// so that every Params sharing the DynamicLog keeps its own.
+func ResolveLog(conf *LogConfig) *LogConfig {
+	if conf == nil || conf.dynamic == nil {
+		return conf
+	}
+	c := conf.dynamic.Load()
+	if conf.Errors == nil && conf.TLS == nil && conf.Interceptors == nil {
+		return c
+	}
+	c2 := *c
+	if conf.Errors != nil {
+		c2.Errors = conf.Errors
+	}
+	if conf.TLS != nil {
+		c2.TLS = conf.TLS
+	}
+	if conf.Interceptors != nil {
+		c2.Interceptors = conf.Interceptors
+	}
+	return &c2
+}
+
+// ServeHTTP reads (GET) or writes (PUT, POST) the LogConfig as JSON.
+func (d *DynamicLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var c LogConfig
+		if err = json.Unmarshal(b, &c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.Store(&c)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(d.Load())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// LoadFile reads the config from a file. unmarshal is json.Unmarshal by default; pass yaml.Unmarshal for a yaml file.
+func (d *DynamicLog) LoadFile(path string, unmarshal func([]byte, interface{}) error) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	var c LogConfig
+	if err = unmarshal(b, &c); err != nil {
+		return err
+	}
+	d.Store(&c)
+	return nil
+}
+
+// WatchFile reloads the config whenever the modification time of the file changes, until ctx is done.
+func (d *DynamicLog) WatchFile(ctx context.Context, path string, interval time.Duration, unmarshal func([]byte, interface{}) error, options ...func(context.Context, string, map[string]interface{})) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || !info.ModTime().After(modified) {
+					continue
+				}
+				modified = info.ModTime()
+				if err = d.LoadFile(path, unmarshal); err != nil && len(options) > 0 && options[0] != nil {
+					options[0](ctx, "cannot reload log config from "+path, map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+// ReloadOnSignal calls load to get the new config when one of the signals (such as syscall.SIGHUP) is received, until ctx is done.
+func (d *DynamicLog) ReloadOnSignal(ctx context.Context, load func() (*LogConfig, error), signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if c, err := load(); err == nil {
+					d.Store(c)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDynamicLogKeepsBoundFields(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Test")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"code":"E42"}}`))
+	}))
+	defer srv.Close()
+
+	d := NewDynamicLog(&LogConfig{Status: StrictStatus()})
+	rules := &ErrorRules{Rules: []ErrorRule{{Status: "5xx", Severity: "high", Path: "error.code"}}}
+	p, err := InitParams(ClientConf{Endpoint: Endpoint{Name: "billing", Url: srv.URL}, Errors: rules, Log: d.Config()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Use(func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Test", "used")
+			return next(req)
+		}
+	})
+	// a reload must not drop the fields bound to the params
+	d.Store(&LogConfig{Status: StrictStatus()})
+
+	var res map[string]interface{}
+	err = Get(context.Background(), p.Client, srv.URL, &res, p.Config)
+	e, ok := IsHttpError(err)
+	if !ok {
+		t.Fatalf("expected HttpError, got %v", err)
+	}
+	if e.Service != "billing" || e.Severity != "high" || e.ErrorCode != "E42" {
+		t.Errorf("error is not classified: service=%q severity=%q code=%q", e.Service, e.Severity, e.ErrorCode)
+	}
+	if header != "used" {
+		t.Errorf("interceptor registered by Use is not called")
+	}
+	if d.Config().Interceptors != nil || d.Load().Interceptors != nil {
+		t.Errorf("Use must not change the shared config")
+	}
+}
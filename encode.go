@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	ContentTypeJSON      = "application/json"
+	ContentTypeForm      = "application/x-www-form-urlencoded"
+	ContentTypeMultipart = "multipart/form-data"
+	ContentTypeBinary    = "application/octet-stream"
+)
+
+// Body is a request body with its content type. It can be passed as obj to Post, Put, Patch, DoWithClient and the Client,
+// or set to the request builder by Body, Form and Multipart.
+// A streamed body (multipart, reader) is logged by its summary instead of its content, and is not retried.
+type Body struct {
+	ContentType string
+	Length      int64  // -1 if the length is unknown, then the body is sent chunked
+	Summary     string // logged instead of the content of a streamed body
+	data        []byte
+	open        func() io.ReadCloser
+	err         error
+	fields      url.Values // the fields of a multipart body
+	files       []FilePart // the files of a multipart body
+}
+
+// Bytes returns the content of a buffered body, or nil for a streamed body.
+func (b *Body) Bytes() []byte {
+	return b.data
+}
+
+// Streamed returns true if the body is read from a reader when the request is sent.
+func (b *Body) Streamed() bool {
+	return b != nil && b.open != nil
+}
+
+// logBytes returns what is logged (and kept in HttpError) for the body.
+func (b *Body) logBytes() []byte {
+	if b == nil {
+		return nil
+	}
+	if b.open != nil {
+		return []byte(b.Summary)
+	}
+	return b.data
+}
+
+// jsonBody is the body of the legacy helpers: Content-Type is application/json, even without body.
+func jsonBody(body []byte) *Body {
+	return &Body{ContentType: ContentTypeJSON, data: body, Length: int64(len(body))}
+}
+func rawBody(body []byte) *Body {
+	return &Body{data: body, Length: int64(len(body))}
+}
+
+// toBody returns obj if it is a Body, the form of url.Values, obj encoded by the codec of the context (see WithCodec),
+// or obj marshalled as JSON by Marshal.
+func toBody(ctx context.Context, obj interface{}) (*Body, error) {
+	switch v := obj.(type) {
+	case *Body:
+		if v == nil {
+			return nil, nil
+		}
+		return v, v.err
+	case url.Values:
+		return FormBody(v), nil
+	}
+	if c := GetCodec(ctx); c != nil {
+		if obj == nil {
+			return &Body{}, nil
+		}
+		return encodeBody(c, obj)
+	}
+	b, err := Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return jsonBody(b), nil
+}
+
+// FormBody encodes url.Values, or a struct or a map by the url tag (see EncodeQuery), as application/x-www-form-urlencoded.
+// An encoding error is returned when the body is sent.
+func FormBody(v interface{}, options ...QueryOptions) *Body {
+	values, err := EncodeQuery(v, options...)
+	if err != nil {
+		return &Body{ContentType: ContentTypeForm, err: err}
+	}
+	data := []byte(values.Encode())
+	return &Body{ContentType: ContentTypeForm, data: data, Length: int64(len(data))}
+}
+
+// ReaderBody sends r as is. If length is negative, the length is unknown and the body is sent chunked.
+// If r is an io.ReadCloser, it is closed by the transport.
+func ReaderBody(r io.Reader, contentType string, length int64) *Body {
+	if len(contentType) == 0 {
+		contentType = ContentTypeBinary
+	}
+	if length < 0 {
+		length = -1
+	}
+	summary := fmt.Sprintf("[stream %s, %d bytes]", contentType, length)
+	if length < 0 {
+		summary = fmt.Sprintf("[stream %s, unknown length]", contentType)
+	}
+	return &Body{ContentType: contentType, Length: length, Summary: summary, open: func() io.ReadCloser {
+		if rc, ok := r.(io.ReadCloser); ok {
+			return rc
+		}
+		return io.NopCloser(r)
+	}}
+}
+
+// FilePart is a file of a multipart body; Reader is streamed when the request is sent. ContentType is application/octet-stream by default.
+type FilePart struct {
+	Field       string
+	FileName    string
+	ContentType string
+	Reader      io.Reader
+}
+
+// MultipartBody streams fields (url.Values, or a struct or a map encoded by EncodeQuery) and files as multipart/form-data, without buffering the files.
+// The length is unknown, so the body is sent chunked.
+func MultipartBody(fields interface{}, files ...FilePart) *Body {
+	values, err := EncodeQuery(fields)
+	if err != nil {
+		return &Body{ContentType: ContentTypeMultipart, err: err}
+	}
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	b := &Body{
+		ContentType: ContentTypeMultipart + "; boundary=" + boundary,
+		Length:      -1,
+		Summary:     multipartSummary(values, files),
+		fields:      values,
+		files:       files,
+	}
+	b.open = func() io.ReadCloser {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeMultipart(pw, boundary, values, files))
+		}()
+		return pr
+	}
+	return b
+}
+
+func writeMultipart(w io.Writer, boundary string, values url.Values, files []FilePart) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(values) {
+		for _, v := range values[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.FileName)))
+		contentType := f.ContentType
+		if len(contentType) == 0 {
+			contentType = ContentTypeBinary
+		}
+		h.Set("Content-Type", contentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if f.Reader != nil {
+			if _, err := io.Copy(part, f.Reader); err != nil {
+				return err
+			}
+		}
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func sortedKeys(values url.Values) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func multipartSummary(values url.Values, files []FilePart) string {
+	var s strings.Builder
+	s.WriteString("[multipart/form-data: fields ")
+	s.WriteString(strings.Join(sortedKeys(values), ","))
+	s.WriteString("; files")
+	for i, f := range files {
+		if i > 0 {
+			s.WriteString(",")
+		}
+		s.WriteString(" " + f.Field + "=" + f.FileName)
+	}
+	s.WriteString("]")
+	return s.String()
+}
+
+// setBody sets the body and its length to the request; a buffered body can be sent again on redirect.
+func setBody(req *http.Request, b *Body) {
+	if b == nil {
+		return
+	}
+	if b.open != nil {
+		if b.Length == 0 {
+			req.Body = http.NoBody
+			return
+		}
+		req.Body = b.open()
+		req.ContentLength = b.Length
+	} else if b.data != nil {
+		data := b.data
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		if len(data) == 0 {
+			req.Body = http.NoBody
+			req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		}
+	}
+}
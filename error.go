@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+const (
+	ErrorTypeTimeout     = "timeout"
+	ErrorTypeCircuitOpen = "circuit_open"
+	ErrorTypeRateLimited = "rate_limited"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrServiceUnavailable = errors.New("service unavailable")
+	ErrTimeout            = errors.New("timeout")
+	ErrCircuitOpen        = errors.New("circuit open")
+)
+
+func (e *HttpError) Unwrap() error {
+	return e.RootError
+}
+
+// Is matches the sentinel errors by status code or error type, so that errors.Is(err, ErrNotFound) works on wrapped HttpError.
+func (e *HttpError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests || e.ErrorType == ErrorTypeRateLimited
+	case ErrServiceUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrTimeout:
+		return e.timeout()
+	case ErrCircuitOpen:
+		return e.ErrorType == ErrorTypeCircuitOpen
+	}
+	return false
+}
+
+func (e *HttpError) timeout() bool {
+	if e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout || e.ErrorType == ErrorTypeTimeout {
+		return true
+	}
+	if e.RootError == nil {
+		return false
+	}
+	if errors.Is(e.RootError, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(e.RootError, &ne) && ne.Timeout()
+}
+
+// Temporary reports whether the same request may succeed later without any change: timeout, 408, 429, 503, 504.
+func (e *HttpError) Temporary() bool {
+	if e.ErrorType == ErrorTypeCircuitOpen {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return e.timeout()
+}
+
+// Retryable reports whether the request can be retried: temporary errors, 500, 502 and network errors without any response.
+func (e *HttpError) Retryable() bool {
+	if e.ErrorType == ErrorTypeCircuitOpen {
+		return false
+	}
+	if e.Temporary() {
+		return true
+	}
+	switch e.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway:
+		return true
+	}
+	return e.StatusCode == 0 && e.RootError != nil && !errors.Is(e.RootError, context.Canceled)
+}
+
+func IsTemporary(err error) bool {
+	if e, ok := IsHttpError(err); ok {
+		return e.Temporary()
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+func IsRetryable(err error) bool {
+	if e, ok := IsHttpError(err); ok {
+		return e.Retryable()
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,348 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type HarConfig struct {
+	Dir         string        `yaml:"dir" mapstructure:"dir" json:"dir,omitempty" gorm:"column:dir" bson:"dir,omitempty" dynamodbav:"dir,omitempty" firestore:"dir,omitempty"`
+	Prefix      string        `yaml:"prefix" mapstructure:"prefix" json:"prefix,omitempty" gorm:"column:prefix" bson:"prefix,omitempty" dynamodbav:"prefix,omitempty" firestore:"prefix,omitempty"`
+	MaxEntries  int           `yaml:"max_entries" mapstructure:"max_entries" json:"maxEntries,omitempty" gorm:"column:maxentries" bson:"maxEntries,omitempty" dynamodbav:"maxEntries,omitempty" firestore:"maxEntries,omitempty"`
+	MaxSize     int64         `yaml:"max_size" mapstructure:"max_size" json:"maxSize,omitempty" gorm:"column:maxsize" bson:"maxSize,omitempty" dynamodbav:"maxSize,omitempty" firestore:"maxSize,omitempty"`
+	MaxFiles    int           `yaml:"max_files" mapstructure:"max_files" json:"maxFiles,omitempty" gorm:"column:maxfiles" bson:"maxFiles,omitempty" dynamodbav:"maxFiles,omitempty" firestore:"maxFiles,omitempty"`
+	MaxBodySize int           `yaml:"max_body_size" mapstructure:"max_body_size" json:"maxBodySize,omitempty" gorm:"column:maxbodysize" bson:"maxBodySize,omitempty" dynamodbav:"maxBodySize,omitempty" firestore:"maxBodySize,omitempty"`
+	Redact      *RedactConfig `yaml:"redact" mapstructure:"redact" json:"redact,omitempty" gorm:"column:redact" bson:"redact,omitempty" dynamodbav:"redact,omitempty" firestore:"redact,omitempty"`
+}
+
+type Har struct {
+	Log HarLog `json:"log"`
+}
+type HarLog struct {
+	Version string     `json:"version"`
+	Creator HarCreator `json:"creator"`
+	Entries []HarEntry `json:"entries"`
+}
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+type HarEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+type HarRequest struct {
+	Method      string         `json:"method"`
+	Url         string         `json:"url"`
+	HttpVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	PostData    *HarPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+type HarResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HttpVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	Content     HarContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+type HarPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+type HarContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+type HarTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HarRecorder is a RoundTripper to record the requests and responses as HTTP Archive (HAR 1.2) files.
+// A file is written when it has MaxEntries entries or MaxSize bytes of bodies; only the last MaxFiles files are kept.
+type HarRecorder struct {
+	next     http.RoundTripper
+	conf     HarConfig
+	redactor *Redactor
+	mu       sync.Mutex
+	entries  []HarEntry
+	size     int64
+	seq      int
+	files    []string
+	err      error
+}
+
+func NewHarRecorder(next http.RoundTripper, c HarConfig) *HarRecorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if len(c.Dir) == 0 {
+		c.Dir = "."
+	}
+	if len(c.Prefix) == 0 {
+		c.Prefix = "client"
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 1000
+	}
+	return &HarRecorder{next: next, conf: c, redactor: GetRedactor(c.Redact)}
+}
+
+// Record returns a copy of client, which sends the requests through the recorder, such as NewHarRecorder(client.Transport, c).Record(client).
+func (h *HarRecorder) Record(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	c2 := *client
+	c2.Transport = h
+	return &c2
+}
+
+// RoundTrip sends a clone of req, and records the entry when the response body is read or closed.
+// The bodies are captured while they are read by the transport and the caller, up to MaxBodySize bytes, so that streamed bodies are not buffered.
+func (h *HarRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody *harBody
+	if req.Body != nil && req.Body != http.NoBody {
+		reqBody = &harBody{ReadCloser: req.Body, max: h.conf.MaxBodySize}
+		req = req.Clone(req.Context())
+		req.Body = reqBody
+	}
+	start := time.Now()
+	res, err := h.next.RoundTrip(req)
+	wait := time.Since(start)
+	if err != nil || res.Body == nil || res.Body == http.NoBody {
+		h.add(h.entry(start, wait, 0, req, reqBody, res, nil, err))
+		return res, err
+	}
+	t := time.Now()
+	resBody := &harBody{ReadCloser: res.Body, max: h.conf.MaxBodySize}
+	resBody.done = func(er1 error) {
+		h.add(h.entry(start, wait, time.Since(t), req, reqBody, res, resBody, er1))
+	}
+	res.Body = resBody
+	return res, nil
+}
+
+// harBody captures the first max bytes (all bytes if max is not positive) of a body and counts its size, while it is read.
+// done is called once, at the end of the body, on a read error or when the body is closed.
+type harBody struct {
+	io.ReadCloser
+	max  int
+	mu   sync.Mutex
+	data []byte
+	size int64
+	once sync.Once
+	done func(err error)
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.size += int64(n)
+	k := n
+	if b.max > 0 && len(b.data)+k > b.max {
+		k = b.max - len(b.data)
+	}
+	b.data = append(b.data, p[:k]...)
+	b.mu.Unlock()
+	if err == io.EOF {
+		b.end(nil)
+	} else if err != nil {
+		b.end(err)
+	}
+	return n, err
+}
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end(nil)
+	return err
+}
+func (b *harBody) end(err error) {
+	if b.done != nil {
+		b.once.Do(func() { b.done(err) })
+	}
+}
+
+// captured returns the captured bytes and the size of the body read; a nil body is empty.
+func (b *harBody) captured() ([]byte, int64) {
+	if b == nil {
+		return nil, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data, b.size
+}
+
+func (h *HarRecorder) entry(start time.Time, wait time.Duration, receive time.Duration, req *http.Request, reqBody *harBody, res *http.Response, resBody *harBody, err error) HarEntry {
+	r := h.redactor
+	u := r.Url(req.URL.String())
+	reqData, reqSize := reqBody.captured()
+	e := HarEntry{
+		StartedDateTime: start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            ms(wait + receive),
+		Timings:         HarTimings{Wait: ms(wait), Receive: ms(receive)},
+		Request: HarRequest{
+			Method:      req.Method,
+			Url:         u,
+			HttpVersion: req.Proto,
+			Cookies:     []HarNameValue{},
+			Headers:     nameValues(r.Headers(req.Header)),
+			QueryString: []HarNameValue{},
+			HeadersSize: -1,
+			BodySize:    int(reqSize),
+		},
+	}
+	if x, er1 := url.Parse(u); er1 == nil {
+		e.Request.QueryString = nameValues(x.Query())
+	}
+	if reqSize > 0 {
+		contentType := req.Header.Get("Content-Type")
+		e.Request.PostData = &HarPostData{MimeType: contentType, Text: h.text(contentType, reqData, reqSize)}
+	}
+	if res == nil {
+		e.Error = err.Error()
+		e.Response = HarResponse{Cookies: []HarNameValue{}, Headers: []HarNameValue{}, HeadersSize: -1, BodySize: -1}
+		return e
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	resData, resSize := resBody.captured()
+	contentType := res.Header.Get("Content-Type")
+	e.Response = HarResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HttpVersion: res.Proto,
+		Cookies:     []HarNameValue{},
+		Headers:     nameValues(r.Headers(res.Header)),
+		Content:     HarContent{Size: int(resSize), MimeType: contentType},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int(resSize),
+	}
+	if len(resData) > 0 {
+		if IsText(contentType, string(resData)) {
+			e.Response.Content.Text = h.text(contentType, resData, resSize)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(resData)
+			e.Response.Content.Encoding = "base64"
+		}
+	}
+	return e
+}
+
+// text returns the redacted text of the captured body, with a marker if the body has more than the captured bytes.
+// The redactor masks the fields of a captured JSON body cut by MaxBodySize too (see Redactor.Body).
+func (h *HarRecorder) text(contentType string, body []byte, size int64) string {
+	if !IsText(contentType, string(body)) {
+		return fmt.Sprintf("[binary %s, %d bytes]", contentTypeOrUnknown(contentType), size)
+	}
+	s := h.redactor.Body(string(body))
+	if size > int64(len(body)) {
+		s = fmt.Sprintf("%s...[truncated %d bytes]", s, size-int64(len(body)))
+	}
+	return s
+}
+
+func (h *HarRecorder) add(e HarEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+	h.size += int64(len(e.Response.Content.Text))
+	if e.Request.PostData != nil {
+		h.size += int64(len(e.Request.PostData.Text))
+	}
+	if len(h.entries) >= h.conf.MaxEntries || (h.conf.MaxSize > 0 && h.size >= h.conf.MaxSize) {
+		if err := h.flush(); err != nil && h.err == nil {
+			h.err = err
+		}
+	}
+}
+
+// Flush writes the recorded entries into a new file.
+// It returns the error of the write, or else the first error of the writes done when the recorder was full, since the last Flush.
+func (h *HarRecorder) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.flush()
+	if err == nil {
+		err = h.err
+	}
+	h.err = nil
+	return err
+}
+func (h *HarRecorder) Close() error {
+	return h.Flush()
+}
+func (h *HarRecorder) flush() error {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	har := Har{Log: HarLog{Version: "1.2", Creator: HarCreator{Name: "github.com/core-go/client", Version: "1.0"}, Entries: h.entries}}
+	h.entries = nil
+	h.size = 0
+	b, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+	h.seq++
+	name := filepath.Join(h.conf.Dir, fmt.Sprintf("%s-%s-%d.har", h.conf.Prefix, time.Now().Format("20060102T150405"), h.seq))
+	if err = os.WriteFile(name, b, 0o644); err != nil {
+		return err
+	}
+	h.files = append(h.files, name)
+	if h.conf.MaxFiles > 0 {
+		for len(h.files) > h.conf.MaxFiles {
+			os.Remove(h.files[0])
+			h.files = h.files[1:]
+		}
+	}
+	return nil
+}
+
+func nameValues(h map[string][]string) []HarNameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	nv := make([]HarNameValue, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range h[k] {
+			nv = append(nv, HarNameValue{Name: k, Value: v})
+		}
+	}
+	return nv
+}
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
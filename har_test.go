@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHarRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("0123456789"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	h := NewHarRecorder(srv.Client().Transport, HarConfig{Dir: t.TempDir(), MaxBodySize: 8})
+	client := h.Record(srv.Client())
+	body := io.NopCloser(strings.NewReader("abcdefghijkl"))
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, body)
+	req.Header.Set("Content-Type", "text/plain")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Body != body {
+		t.Errorf("the body of the request of the caller must not be replaced")
+	}
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if len(b) != 30 {
+		t.Fatalf("the caller must read the whole body, got %d bytes", len(b))
+	}
+	if len(h.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(h.entries))
+	}
+	e := h.entries[0]
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"request size", e.Request.BodySize, 12},
+		{"request text", e.Request.PostData.Text, "abcdefgh...[truncated 4 bytes]"},
+		{"response size", e.Response.BodySize, 30},
+		{"response text", e.Response.Content.Text, "01234567...[truncated 22 bytes]"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if err := h.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHarRecorderFlushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	h := NewHarRecorder(srv.Client().Transport, HarConfig{Dir: filepath.Join(t.TempDir(), "missing"), MaxEntries: 1})
+	res, err := h.Record(srv.Client()).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if err := h.Flush(); err == nil {
+		t.Errorf("the error of the write when the recorder is full must be returned")
+	}
+	if err := h.Flush(); err != nil {
+		t.Errorf("the error must be returned once, got %v", err)
+	}
+}
+
+func TestHarRecorderRedactsTruncatedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"user":"tom","password":"hunter2","message":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer srv.Close()
+
+	h := NewHarRecorder(srv.Client().Transport, HarConfig{Dir: t.TempDir(), MaxBodySize: 50, Redact: &RedactConfig{Fields: []string{"password"}}})
+	res, err := h.Record(srv.Client()).Post(srv.URL, "application/json", strings.NewReader(`{"password":"hunter2","user":"`+strings.Repeat("y", 100)+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res.Body)
+	e := h.entries[0]
+	for _, s := range []string{e.Request.PostData.Text, e.Response.Content.Text} {
+		if strings.Contains(s, "hunter2") || !strings.Contains(s, `"password":"***"`) || !strings.Contains(s, "...[truncated") {
+			t.Errorf("the truncated body must be redacted, got %q", s)
+		}
+	}
+}
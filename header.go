@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// HeaderRecorder is a RoundTripper to record the headers of the last request and response, and the status and size of the response.
+type HeaderRecorder struct {
+	next          http.RoundTripper
+	mu            sync.Mutex
+	request       http.Header
+	response      http.Header
+	status        int
+	contentLength int64
+}
+
+func NewHeaderRecorder(next http.RoundTripper) *HeaderRecorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &HeaderRecorder{next: next}
+}
+func (h *HeaderRecorder) Request() http.Header {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.request
+}
+func (h *HeaderRecorder) Response() http.Header {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.response
+}
+func (h *HeaderRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.request = req.Header.Clone()
+	h.mu.Unlock()
+	res, err := h.next.RoundTrip(req)
+	if res != nil {
+		h.mu.Lock()
+		h.response = res.Header.Clone()
+		h.status = res.StatusCode
+		h.contentLength = res.ContentLength
+		h.mu.Unlock()
+	}
+	return res, err
+}
+func (h *HeaderRecorder) Status() (int, int64) {
+	if h == nil {
+		return 0, -1
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status, h.contentLength
+}
+
+// LogHeaders records the headers of the request and response sent by client,
+// to add them into the request_headers, response_headers fields of the logs, and to build the curl command.
+// If client is not *http.Client, the recorder wraps its Do method.
+func LogHeaders(conf *LogConfig, client Doer, options []func(context.Context, string, map[string]interface{})) (Doer, []func(context.Context, string, map[string]interface{}), *HeaderRecorder) {
+	if conf == nil || isNilDoer(client) || (len(conf.RequestHeaders) == 0 && len(conf.ResponseHeaders) == 0 && len(conf.Curl) == 0 && conf.Metrics == nil && conf.Tracer == nil) {
+		return client, options, nil
+	}
+	var rec *HeaderRecorder
+	var c2 Doer
+	if c, ok := client.(*http.Client); ok {
+		rec = NewHeaderRecorder(c.Transport)
+		c3 := *c
+		c3.Transport = rec
+		c2 = &c3
+	} else {
+		rec = NewHeaderRecorder(RoundTripperFunc(client.Do))
+		c2 = DoerFunc(rec.RoundTrip)
+	}
+	r := redactorOf(conf)
+	return c2, wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return func(ctx context.Context, msg string, fields map[string]interface{}) {
+			request, response := rec.Request(), rec.Response()
+			if len(conf.RequestHeaders) > 0 && request != nil {
+				fields[conf.RequestHeaders] = FilterHeaders(request, conf.AllowRequestHeaders, r)
+			}
+			if len(conf.ResponseHeaders) > 0 && response != nil {
+				fields[conf.ResponseHeaders] = FilterHeaders(response, conf.AllowResponseHeaders, r)
+			}
+			log(ctx, msg, fields)
+		}
+	}), rec
+}
+
+// FilterHeaders keeps the allowed headers (all headers if allow is empty), and masks the headers denied by the redactor.
+func FilterHeaders(h http.Header, allow []string, r *Redactor) map[string]string {
+	mp := make(map[string]string)
+	if len(allow) > 0 {
+		for _, k := range allow {
+			k = textproto.CanonicalMIMEHeaderKey(k)
+			if v, ok := h[k]; ok {
+				mp[k] = headerValue(k, v, r)
+			}
+		}
+		return mp
+	}
+	for k, v := range h {
+		mp[k] = headerValue(k, v, r)
+	}
+	return mp
+}
+func headerValue(k string, v []string, r *Redactor) string {
+	if r != nil && r.Header(k) {
+		return r.mask
+	}
+	return strings.Join(v, ", ")
+}
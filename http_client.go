@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Client calls the endpoint of ClientConf: the paths are resolved against Url, the default headers are merged with the headers of every call,
+// and the calls are logged by its own log config and log functions.
+type Client struct {
+	Client   Doer
+	Url      string
+	Header   map[string]string
+	Config   *LogConfig
+	LogError func(context.Context, string, map[string]interface{})
+	LogInfo  func(context.Context, string, map[string]interface{})
+	// Codec encodes the request bodies and sets Accept; nil is JSON without Accept. The response is decoded by its Content-Type.
+	Codec Codec
+}
+
+// New creates a Client from the config; opts are the log functions (logError, logInfo), as InitParams.
+func New(config ClientConf, opts ...func(context.Context, string, map[string]interface{})) (*Client, error) {
+	p, err := InitParams(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return FromParams(p), nil
+}
+func FromParams(p *Params) *Client {
+	c := &Client{Url: p.Url, Header: p.Header, Config: p.Config, LogError: p.LogError, LogInfo: p.LogInfo, Codec: p.Codec}
+	if p.Client != nil {
+		c.Client = p.Client
+	}
+	return c
+}
+
+// ResolveUrl joins the base url and path. If path is an absolute url, it is returned as is.
+func (c *Client) ResolveUrl(path string) string {
+	if len(c.Url) == 0 || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if len(path) == 0 {
+		return c.Url
+	}
+	if path[0] == '?' {
+		return strings.TrimSuffix(c.Url, "/") + path
+	}
+	return strings.TrimSuffix(c.Url, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// MergeHeaders returns the default headers of the client, overridden by headers.
+func (c *Client) MergeHeaders(headers ...map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return c.Header
+	}
+	h := make(map[string]string, len(c.Header))
+	for k, v := range c.Header {
+		h[k] = v
+	}
+	for _, x := range headers {
+		for k, v := range x {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+func (c *Client) Get(ctx context.Context, path string, result interface{}, headers ...map[string]string) error {
+	return c.call(ctx, http.MethodGet, path, nil, result, headers)
+}
+func (c *Client) Delete(ctx context.Context, path string, result interface{}, headers ...map[string]string) error {
+	return c.call(ctx, http.MethodDelete, path, nil, result, headers)
+}
+func (c *Client) Post(ctx context.Context, path string, obj interface{}, result interface{}, headers ...map[string]string) error {
+	return c.call(ctx, http.MethodPost, path, obj, result, headers)
+}
+func (c *Client) Put(ctx context.Context, path string, obj interface{}, result interface{}, headers ...map[string]string) error {
+	return c.call(ctx, http.MethodPut, path, obj, result, headers)
+}
+func (c *Client) Patch(ctx context.Context, path string, obj interface{}, result interface{}, headers ...map[string]string) error {
+	return c.call(ctx, http.MethodPatch, path, obj, result, headers)
+}
+
+// Do sends obj encoded by the codec of the client (no body if obj is nil), or as is if obj is a Body, and returns the response, which must be closed by the caller.
+func (c *Client) Do(ctx context.Context, method string, path string, obj interface{}, headers ...map[string]string) (*http.Response, error) {
+	ctx = c.withCodec(ctx)
+	body, err := marshalBody(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return execute(ctx, c.Client, method, c.ResolveUrl(path), body, c.MergeHeaders(headers...), c.Config, c.options())
+}
+
+func (c *Client) call(ctx context.Context, method string, path string, obj interface{}, result interface{}, headers []map[string]string) error {
+	ctx = c.withCodec(ctx)
+	body, err := marshalBody(ctx, obj)
+	if err != nil {
+		return err
+	}
+	res, err := execute(ctx, c.Client, method, c.ResolveUrl(path), body, c.MergeHeaders(headers...), c.Config, c.options(), statusInterceptor)
+	if err != nil {
+		if res != nil {
+			DrainAndClose(res.Body)
+		}
+		return err
+	}
+	return DecodeAndClose(res, result)
+}
+
+// withCodec sets the codec of the client to the context, if the context has no codec.
+func (c *Client) withCodec(ctx context.Context) context.Context {
+	if c.Codec == nil || GetCodec(ctx) != nil {
+		return ctx
+	}
+	return WithCodec(ctx, c.Codec)
+}
+func (c *Client) options() []func(context.Context, string, map[string]interface{}) {
+	return []func(context.Context, string, map[string]interface{}){c.LogError, c.LogInfo}
+}
+
+// marshalBody returns a body without content for a nil obj, so that no body is sent.
+func marshalBody(ctx context.Context, obj interface{}) (*Body, error) {
+	if obj == nil && GetCodec(ctx) == nil {
+		return jsonBody(nil), nil
+	}
+	return toBody(ctx, obj)
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"time"
+)
+
+// Handler sends the request and returns the response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Interceptor wraps a Handler, to run before and after the next handler of the chain.
+type Interceptor func(next Handler) Handler
+
+// TransportMiddleware wraps the RoundTripper of the http client, such as NewHeaderRecorder or NewHarRecorder.
+type TransportMiddleware func(next http.RoundTripper) http.RoundTripper
+
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// DefaultInterceptors is the chain of the helpers when LogConfig.Interceptors is nil. The first interceptor is the outermost.
+var DefaultInterceptors = []Interceptor{LogInterceptor, ClassifyInterceptor, ServiceUnavailableInterceptor}
+
+// Chain wraps h by the interceptors; the first interceptor is the outermost.
+func Chain(h Handler, interceptors ...Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if interceptors[i] != nil {
+			h = interceptors[i](h)
+		}
+	}
+	return h
+}
+
+// Interceptors returns the interceptors of conf, or DefaultInterceptors if conf has none.
+// To reorder or remove the built-in interceptors, set LogConfig.Interceptors to the full chain.
+func Interceptors(conf *LogConfig) []Interceptor {
+	if conf == nil || conf.Interceptors == nil {
+		return DefaultInterceptors
+	}
+	return conf.Interceptors
+}
+
+// Wrap returns a copy of client, which sends the requests through the middlewares; the first middleware is the outermost.
+func Wrap(client *http.Client, middlewares ...TransportMiddleware) *http.Client {
+	if len(middlewares) == 0 {
+		return client
+	}
+	if client == nil {
+		client = &http.Client{}
+	}
+	t := client.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			t = middlewares[i](t)
+		}
+	}
+	c2 := *client
+	c2.Transport = t
+	return &c2
+}
+
+// Use appends the interceptors to the chain of the params, after the current ones.
+func (p *Params) Use(interceptors ...Interceptor) {
+	if p.Config == nil {
+		// the keys of the error logs without config; the info logs stay off
+		p.Config = defaultLogKeys()
+	} else if d := p.Config.dynamic; d != nil && p.Config == d.handle {
+		// the handle is shared by all Params of the DynamicLog
+		c := *p.Config
+		p.Config = &c
+	}
+	p.Config.Interceptors = appendInterceptors(Interceptors(ResolveLog(p.Config)), interceptors...)
+}
+
+// UseTransport wraps the http client of the params by the middlewares.
+func (p *Params) UseTransport(middlewares ...TransportMiddleware) {
+	p.Client = Wrap(p.Client, middlewares...)
+}
+
+func appendInterceptors(interceptors []Interceptor, more ...Interceptor) []Interceptor {
+	s := make([]Interceptor, 0, len(interceptors)+len(more))
+	s = append(s, interceptors...)
+	return append(s, more...)
+}
+
+type callKey struct{}
+
+// call is the state of the helpers, which is passed to the built-in interceptors by the context.
+type call struct {
+	conf     *LogConfig
+	body     []byte
+	logError func(context.Context, string, map[string]interface{})
+	logInfo  func(context.Context, string, map[string]interface{})
+	// header is the header of the request passed to the client, after all the interceptors
+	header http.Header
+}
+
+func withCall(ctx context.Context, conf *LogConfig, body []byte, options []func(context.Context, string, map[string]interface{})) context.Context {
+	c := &call{conf: conf, body: body}
+	if len(options) > 0 {
+		c.logError = options[0]
+	}
+	if len(options) > 1 {
+		c.logInfo = options[1]
+	}
+	return context.WithValue(ctx, callKey{}, c)
+}
+func getCall(ctx context.Context) *call {
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		return c
+	}
+	return &call{}
+}
+
+// LogInterceptor logs the errors and 4xx, 5xx responses by the error log function, and the other calls by the info log function if LogConfig.Log is on.
+func LogInterceptor(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		c := getCall(ctx)
+		conf := c.conf
+		logInfo := c.logInfo
+		if conf == nil || !conf.Log {
+			logInfo = nil
+		}
+		if c.logError == nil && logInfo == nil {
+			return next(req)
+		}
+		msg := req.Method + " " + req.URL.String()
+		canRequest := req.Method != "GET" && req.Method != "DELETE" && req.Method != "OPTIONS"
+		if logInfo != nil && conf.Separate && len(conf.Request) > 0 && len(c.body) > 0 && canRequest {
+			logInfo(ctx, msg, map[string]interface{}{conf.Request: string(c.body)})
+		}
+		start := time.Now()
+		res, err := next(req)
+		dur := time.Since(start).Milliseconds()
+		if c.logError != nil && (res == nil || res.StatusCode >= 400) {
+			keys := defaultLogKeys()
+			if conf != nil {
+				keys = conf
+			}
+			fields := make(map[string]interface{})
+			if len(keys.Duration) > 0 {
+				fields[keys.Duration] = dur
+			}
+			if len(keys.Request) > 0 && len(c.body) > 0 {
+				fields[keys.Request] = string(c.body)
+			}
+			res, err = logResponse(fields, keys, res, err)
+			ClassifiedFields(fields, keys, classifyResponse(conf, req, res, err, dur, c.body))
+			c.logError(ctx, msg, fields)
+			return res, err
+		}
+		if logInfo != nil {
+			fields := make(map[string]interface{})
+			if len(conf.Duration) > 0 {
+				fields[conf.Duration] = dur
+			}
+			if !conf.Separate && len(conf.Request) > 0 && len(c.body) > 0 && canRequest {
+				fields[conf.Request] = string(c.body)
+			}
+			res, err = logResponse(fields, conf, res, err)
+			logInfo(ctx, msg, fields)
+		}
+		return res, err
+	}
+}
+
+// defaultLogKeys returns the keys of the error logs of the calls without LogConfig.
+func defaultLogKeys() *LogConfig {
+	return &LogConfig{Duration: "duration", Request: "request", Response: "response", ResponseStatus: "status", Error: "error"}
+}
+func logResponse(fields map[string]interface{}, c *LogConfig, res *http.Response, err error) (*http.Response, error) {
+	if res == nil {
+		if err != nil && len(c.Error) > 0 {
+			fields[c.Error] = err.Error()
+		}
+		return res, err
+	}
+	if len(c.ResponseStatus) > 0 {
+		fields[c.ResponseStatus] = res.StatusCode
+	}
+	if len(c.Size) > 0 && res.ContentLength > 0 {
+		fields[c.Size] = res.ContentLength
+	}
+	if len(c.Response) > 0 && c.MaxBodySize > 0 && res.Body != nil && res.Body != http.NoBody {
+		return logLimitedResponse(fields, c, res, err)
+	}
+	if len(c.Response) > 0 {
+		dump, er1 := httputil.DumpResponse(res, true)
+		if er1 != nil {
+			if len(c.Error) > 0 {
+				fields[c.Error] = er1.Error()
+			}
+			if err == nil {
+				err = er1
+			}
+			return res, err
+		}
+		s := string(dump)
+		if len(c.Size) > 0 {
+			fields[c.Size] = len(s)
+		}
+		fields[c.Response] = s
+	}
+	return res, err
+}
+
+// logLimitedResponse logs the headers and at most MaxBodySize bytes of the body, so that a large or streamed body is not read before it is truncated.
+// The bytes read are put back in front of the body for the caller.
+func logLimitedResponse(fields map[string]interface{}, c *LogConfig, res *http.Response, err error) (*http.Response, error) {
+	head, er1 := httputil.DumpResponse(res, false)
+	var b []byte
+	if er1 == nil {
+		b, er1 = peekBody(res, c.MaxBodySize+1)
+	}
+	if er1 != nil {
+		if len(c.Error) > 0 {
+			fields[c.Error] = er1.Error()
+		}
+		if err == nil {
+			err = er1
+		}
+		return res, err
+	}
+	size := res.ContentLength
+	if len(b) <= c.MaxBodySize {
+		size = int64(len(b))
+	}
+	if len(c.Size) > 0 && size >= 0 {
+		fields[c.Size] = size
+	}
+	fields[c.Response] = string(head) + truncate(string(b), c.MaxBodySize, size)
+	return res, err
+}
+
+// ServiceUnavailableInterceptor returns HttpError with status 503 and the response body, if the status of the response is 503.
+func ServiceUnavailableInterceptor(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next(req)
+		if err != nil || res.StatusCode != http.StatusServiceUnavailable {
+			return res, err
+		}
+		dur := time.Since(start).Milliseconds()
+		b, _ := bufferBody(res)
+		c := getCall(req.Context())
+		err = NewHttpError(http.StatusServiceUnavailable, nil, dur, "503 Service Unavailable", req.URL.String(), string(c.body), string(b))
+		var problem *ProblemConfig
+		if c.conf != nil {
+			problem = c.conf.Problem
+		}
+		SetProblem(err.(*HttpError), res.Header.Get("Content-Type"), b, problem)
+		return res, err
+	}
+}
+
+// statusInterceptor applies the status policy to the response to be decoded.
+func statusInterceptor(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next(req)
+		if err != nil {
+			return res, err
+		}
+		ctx := req.Context()
+		c := getCall(ctx)
+		if er1 := checkStatus(ctx, c.conf, res, time.Since(start).Milliseconds(), req.URL.String(), c.body); er1 != nil {
+			return res, er1
+		}
+		return res, nil
+	}
+}
+
+// AuthInterceptor sets the Authorization header by the value of auth, if the request has no Authorization header.
+func AuthInterceptor(auth func(ctx context.Context) (string, error)) Interceptor {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if len(req.Header.Get("Authorization")) == 0 {
+				v, err := auth(req.Context())
+				if err != nil {
+					return nil, err
+				}
+				if len(v) > 0 {
+					req.Header.Set("Authorization", v)
+				}
+			}
+			return next(req)
+		}
+	}
+}
+func BasicAuthInterceptor(username, password string) Interceptor {
+	v := "Basic " + BasicAuth(username, password)
+	return AuthInterceptor(func(ctx context.Context) (string, error) {
+		return v, nil
+	})
+}
+func BearerAuthInterceptor(token func(ctx context.Context) (string, error)) Interceptor {
+	return AuthInterceptor(func(ctx context.Context) (string, error) {
+		t, err := token(ctx)
+		if err != nil || len(t) == 0 {
+			return "", err
+		}
+		return "Bearer " + t, nil
+	})
+}
+
+// bufferBody reads and closes the body of the response, then replaces it by the read bytes, so that it can be read again.
+// peekBody reads at most max bytes of the response body (all of it if max <= 0), and puts them back in front of the rest of the body.
+func peekBody(res *http.Response, max int) ([]byte, error) {
+	if max <= 0 {
+		return bufferBody(res)
+	}
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+	body := res.Body
+	b, err := io.ReadAll(io.LimitReader(body, int64(max)))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), body), body}
+	return b, err
+}
+
+func bufferBody(res *http.Response) ([]byte, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	return b, err
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUseWithoutConfigKeepsInfoLogOff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var errors, infos int
+	p := &Params{Client: srv.Client(), Url: srv.URL}
+	p.Use(func(next Handler) Handler { return next })
+	if p.Config.Log {
+		t.Fatalf("Use must not turn the info log on")
+	}
+	logError := func(context.Context, string, map[string]interface{}) { errors++ }
+	logInfo := func(context.Context, string, map[string]interface{}) { infos++ }
+	for _, path := range []string{"/ok", "/fail"} {
+		res, err := DoAndLog(context.Background(), p.Client, http.MethodGet, srv.URL+path, nil, nil, p.Config, logError, logInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		DrainAndClose(res.Body)
+	}
+	if errors != 1 || infos != 0 {
+		t.Errorf("expected 1 error log and no info log, got %d, %d", errors, infos)
+	}
+}
+
+func TestWrapOptionsKeepsNil(t *testing.T) {
+	logInfo := func(context.Context, string, map[string]interface{}) {}
+	conf := &LogConfig{RequestId: "requestId", TraceId: "traceId"}
+	tests := []struct {
+		name string
+		opts []func(context.Context, string, map[string]interface{})
+	}{
+		{"LogOptions", LogOptions(conf, []func(context.Context, string, map[string]interface{}){nil, logInfo})},
+		{"LogRequestId", LogRequestId(conf, []func(context.Context, string, map[string]interface{}){nil, logInfo})},
+		{"LogTrace", LogTrace(conf, []func(context.Context, string, map[string]interface{}){nil, logInfo})},
+	}
+	for _, tt := range tests {
+		if len(tt.opts) != 2 || tt.opts[0] != nil || tt.opts[1] == nil {
+			t.Errorf("%s: a nil log function must be kept nil", tt.name)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+type Logger interface {
+	Debug(ctx context.Context, msg string, fields map[string]interface{})
+	Info(ctx context.Context, msg string, fields map[string]interface{})
+	Warn(ctx context.Context, msg string, fields map[string]interface{})
+	Error(ctx context.Context, msg string, fields map[string]interface{})
+}
+
+type LogLevels struct {
+	Success     string `yaml:"success" mapstructure:"success" json:"success,omitempty" gorm:"column:success" bson:"success,omitempty" dynamodbav:"success,omitempty" firestore:"success,omitempty"`
+	ClientError string `yaml:"client_error" mapstructure:"client_error" json:"clientError,omitempty" gorm:"column:clienterror" bson:"clientError,omitempty" dynamodbav:"clientError,omitempty" firestore:"clientError,omitempty"`
+	ServerError string `yaml:"server_error" mapstructure:"server_error" json:"serverError,omitempty" gorm:"column:servererror" bson:"serverError,omitempty" dynamodbav:"serverError,omitempty" firestore:"serverError,omitempty"`
+	Network     string `yaml:"network" mapstructure:"network" json:"network,omitempty" gorm:"column:network" bson:"network,omitempty" dynamodbav:"network,omitempty" firestore:"network,omitempty"`
+}
+
+func InitializeLevels(c *LogLevels) LogLevels {
+	var c2 LogLevels
+	if c != nil {
+		c2 = *c
+	}
+	if len(c2.Success) == 0 {
+		c2.Success = LevelInfo
+	}
+	if len(c2.ClientError) == 0 {
+		c2.ClientError = LevelWarn
+	}
+	if len(c2.ServerError) == 0 {
+		c2.ServerError = LevelError
+	}
+	if len(c2.Network) == 0 {
+		c2.Network = LevelError
+	}
+	return c2
+}
+
+// LoggerOptions returns the log functions (logError, logInfo) to be passed as options, such as Get(ctx, client, url, &res, conf, LoggerOptions(logger, conf)...).
+// The level is chosen by the status field of conf: 4xx, 5xx, network errors (no status) and success are logged at the levels of conf.Levels.
+func LoggerOptions(logger Logger, conf *LogConfig) []func(context.Context, string, map[string]interface{}) {
+	if logger == nil {
+		return nil
+	}
+	log := func(ctx context.Context, msg string, fields map[string]interface{}) {
+		status, errorKey := "status", "error"
+		var levels LogLevels
+		if c := ResolveLog(conf); c != nil {
+			if len(c.ResponseStatus) > 0 {
+				status = c.ResponseStatus
+			}
+			if len(c.Error) > 0 {
+				errorKey = c.Error
+			}
+			levels = InitializeLevels(c.Levels)
+		} else {
+			levels = InitializeLevels(nil)
+		}
+		level := levels.Success
+		if s, ok := fields[status].(int); ok {
+			if s >= 500 {
+				level = levels.ServerError
+			} else if s >= 400 {
+				level = levels.ClientError
+			}
+		} else if _, ok := fields[errorKey]; ok {
+			level = levels.Network
+		}
+		LogAt(logger, level, ctx, msg, fields)
+	}
+	return []func(context.Context, string, map[string]interface{}){log, log}
+}
+
+func LogAt(logger Logger, level string, ctx context.Context, msg string, fields map[string]interface{}) {
+	switch strings.ToLower(level) {
+	case LevelDebug:
+		logger.Debug(ctx, msg, fields)
+	case LevelWarn, "warning":
+		logger.Warn(ctx, msg, fields)
+	case LevelError:
+		logger.Error(ctx, msg, fields)
+	default:
+		logger.Info(ctx, msg, fields)
+	}
+}
+
+type SlogLogger struct {
+	Logger *slog.Logger
+	Group  string
+}
+
+// NewSlogLogger adapts slog.Logger to Logger. The context is passed to the handler; if group is not empty, the fields are put into this attribute group.
+func NewSlogLogger(logger *slog.Logger, opts ...string) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	l := &SlogLogger{Logger: logger}
+	if len(opts) > 0 {
+		l.Group = opts[0]
+	}
+	return l
+}
+func (l *SlogLogger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.log(ctx, slog.LevelDebug, msg, fields)
+}
+func (l *SlogLogger) Info(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.log(ctx, slog.LevelInfo, msg, fields)
+}
+func (l *SlogLogger) Warn(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.log(ctx, slog.LevelWarn, msg, fields)
+}
+func (l *SlogLogger) Error(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.log(ctx, slog.LevelError, msg, fields)
+}
+func (l *SlogLogger) log(ctx context.Context, level slog.Level, msg string, fields map[string]interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !l.Logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := Attrs(fields)
+	if len(l.Group) > 0 {
+		l.Logger.LogAttrs(ctx, level, msg, slog.Attr{Key: l.Group, Value: slog.GroupValue(attrs...)})
+	} else {
+		l.Logger.LogAttrs(ctx, level, msg, attrs...)
+	}
+}
+
+// Attrs converts the fields to slog attributes, sorted by key; nested maps become groups.
+func Attrs(fields map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		switch v := fields[k].(type) {
+		case map[string]interface{}:
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(Attrs(v)...)})
+		case map[string]string:
+			mp := make(map[string]interface{}, len(v))
+			for k2, v2 := range v {
+				mp[k2] = v2
+			}
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(Attrs(mp)...)})
+		default:
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	return attrs
+}
+
+// SlogOptions is a shortcut of LoggerOptions(NewSlogLogger(logger), conf).
+func SlogOptions(logger *slog.Logger, conf *LogConfig) []func(context.Context, string, map[string]interface{}) {
+	return LoggerOptions(NewSlogLogger(logger), conf)
+}
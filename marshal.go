@@ -0,0 +1,319 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type ErrorConfig struct {
+	Problem   bool `yaml:"problem" mapstructure:"problem" json:"problem,omitempty" gorm:"column:problem" bson:"problem,omitempty" dynamodbav:"problem,omitempty" firestore:"problem,omitempty"`
+	Url       bool `yaml:"url" mapstructure:"url" json:"url,omitempty" gorm:"column:url" bson:"url,omitempty" dynamodbav:"url,omitempty" firestore:"url,omitempty"`
+	Request   bool `yaml:"request" mapstructure:"request" json:"request,omitempty" gorm:"column:request" bson:"request,omitempty" dynamodbav:"request,omitempty" firestore:"request,omitempty"`
+	Response  bool `yaml:"response" mapstructure:"response" json:"response,omitempty" gorm:"column:response" bson:"response,omitempty" dynamodbav:"response,omitempty" firestore:"response,omitempty"`
+	RootError bool `yaml:"root_error" mapstructure:"root_error" json:"rootError,omitempty" gorm:"column:rooterror" bson:"rootError,omitempty" dynamodbav:"rootError,omitempty" firestore:"rootError,omitempty"`
+}
+
+type httpErrorJSON struct {
+	Method    string                 `json:"method,omitempty"`
+	Status    int                    `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	RootError string                 `json:"rootError,omitempty"`
+	Url       string                 `json:"url,omitempty"`
+	Request   string                 `json:"request,omitempty"`
+	Response  string                 `json:"response,omitempty"`
+	Duration  int64                  `json:"duration,omitempty"`
+	ErrorType string                 `json:"errorType,omitempty"`
+	ErrorCode string                 `json:"errorCode,omitempty"`
+	Service   string                 `json:"service,omitempty"`
+	Severity  string                 `json:"severity,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Redact returns a copy of e without the fields which are not allowed by c. If c is nil, only url, request, response and root error are removed.
+func (e *HttpError) Redact(c *ErrorConfig) *HttpError {
+	var c2 ErrorConfig
+	if c != nil {
+		c2 = *c
+	}
+	e2 := *e
+	if !c2.Url {
+		e2.Url = ""
+	}
+	if !c2.Request {
+		e2.Request = ""
+	}
+	if !c2.Response {
+		e2.Response = ""
+	}
+	if !c2.RootError && e2.RootError != nil {
+		if len(e2.ErrorMessage) == 0 {
+			e2.ErrorMessage = http.StatusText(e2.StatusCode)
+		}
+		e2.RootError = nil
+	}
+	return &e2
+}
+
+func (e *HttpError) MarshalJSON() ([]byte, error) {
+	v := httpErrorJSON{
+		Method:    e.Method,
+		Status:    e.StatusCode,
+		Error:     e.ErrorMessage,
+		Url:       e.Url,
+		Request:   e.Request,
+		Response:  e.Response,
+		Duration:  e.Duration,
+		ErrorType: e.ErrorType,
+		ErrorCode: e.ErrorCode,
+		Service:   e.Service,
+		Severity:  e.Severity,
+		Details:   e.Details,
+	}
+	if e.RootError != nil {
+		v.RootError = e.RootError.Error()
+	}
+	return json.Marshal(v)
+}
+func (e *HttpError) UnmarshalJSON(data []byte) error {
+	var v httpErrorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = HttpError{
+		Method:       v.Method,
+		StatusCode:   v.Status,
+		ErrorMessage: v.Error,
+		Url:          v.Url,
+		Request:      v.Request,
+		Response:     v.Response,
+		Duration:     v.Duration,
+		ErrorType:    v.ErrorType,
+		ErrorCode:    v.ErrorCode,
+		Service:      v.Service,
+		Severity:     v.Severity,
+		Details:      v.Details,
+	}
+	if len(v.RootError) > 0 {
+		e.RootError = errors.New(v.RootError)
+	}
+	return nil
+}
+
+// problemMembers are the extension members of the problem document of ToProblem, which are the fields of HttpError.
+var problemMembers = map[string]bool{"code": true, "service": true, "severity": true, "method": true, "url": true, "request": true, "response": true, "duration": true, "rootError": true}
+
+// ToProblem builds a RFC 9457 problem document. The members of Details are kept as extension members,
+// so that a problem received from an upstream service is forwarded as is. ParseHttpError is the inverse.
+func (e *HttpError) ToProblem() *Problem {
+	p := &Problem{Type: e.ErrorType, Title: http.StatusText(e.StatusCode), Status: e.StatusCode, Detail: e.ErrorMessage, Extensions: make(map[string]interface{})}
+	for k, v := range e.Details {
+		switch k {
+		case "type", "title", "status", "detail":
+		case "instance":
+			p.Instance = toString(v)
+		default:
+			p.Extensions[k] = v
+		}
+	}
+	if len(p.Type) == 0 {
+		p.Type = "about:blank"
+	}
+	if t, ok := e.Details["title"]; ok {
+		p.Title = toString(t)
+	}
+	if len(p.Title) == 0 {
+		p.Title = e.Error()
+	}
+	if len(e.ErrorCode) > 0 {
+		p.Extensions["code"] = e.ErrorCode
+	}
+	if len(e.Service) > 0 {
+		p.Extensions["service"] = e.Service
+	}
+	if len(e.Severity) > 0 {
+		p.Extensions["severity"] = e.Severity
+	}
+	if len(e.Method) > 0 {
+		p.Extensions["method"] = e.Method
+	}
+	if len(e.Url) > 0 {
+		p.Extensions["url"] = e.Url
+	}
+	if len(e.Request) > 0 {
+		p.Extensions["request"] = e.Request
+	}
+	if len(e.Response) > 0 {
+		p.Extensions["response"] = e.Response
+	}
+	if e.Duration > 0 {
+		p.Extensions["duration"] = e.Duration
+	}
+	if e.RootError != nil {
+		p.Extensions["rootError"] = e.RootError.Error()
+	}
+	return p
+}
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Map())
+}
+
+func MarshalError(e *HttpError, c *ErrorConfig) (string, []byte, error) {
+	e2 := e.Redact(c)
+	if c != nil && c.Problem {
+		b, err := json.Marshal(e2.ToProblem())
+		return ProblemContentType, b, err
+	}
+	b, err := json.Marshal(e2)
+	return "application/json", b, err
+}
+
+// WriteError writes err with the status code of the HttpError. Other errors are written as 500 Internal Server Error.
+func WriteError(w http.ResponseWriter, err error, c *ErrorConfig) {
+	e, ok := IsHttpError(err)
+	if !ok {
+		e = &HttpError{StatusCode: http.StatusInternalServerError, RootError: err}
+	}
+	status := e.StatusCode
+	if status < 400 || status > 599 {
+		status = http.StatusBadGateway
+		e2 := *e
+		e2.StatusCode = status
+		e = &e2
+	}
+	contentType, b, er1 := MarshalError(e, c)
+	if er1 != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(b)
+}
+func NewErrorHandler(err error, c *ErrorConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, err, c)
+	})
+}
+
+// ParseHttpError is the inverse of MarshalError: it accepts both application/problem+json and the JSON of HttpError.
+func ParseHttpError(contentType string, data []byte) (*HttpError, error) {
+	if IsProblem(contentType, nil) {
+		p, ok := ParseProblem(data, nil)
+		if !ok {
+			return nil, fmt.Errorf("invalid problem document")
+		}
+		return FromProblem(p), nil
+	}
+	var e HttpError
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FromProblem is the inverse of ToProblem: the members which are the fields of HttpError are not kept in Details,
+// nor is the title, if it is the one built by ToProblem.
+func FromProblem(p *Problem) *HttpError {
+	e := &HttpError{StatusCode: p.Status, ErrorMessage: p.Detail}
+	if p.Type != "about:blank" {
+		e.ErrorType = p.Type
+	}
+	details := make(map[string]interface{})
+	for k, v := range p.Extensions {
+		if !problemMembers[k] {
+			details[k] = v
+			continue
+		}
+		switch k {
+		case "code":
+			e.ErrorCode = toString(v)
+		case "service":
+			e.Service = toString(v)
+		case "severity":
+			e.Severity = toString(v)
+		case "method":
+			e.Method = toString(v)
+		case "url":
+			e.Url = toString(v)
+		case "request":
+			e.Request = toString(v)
+		case "response":
+			e.Response = toString(v)
+		case "duration":
+			e.Duration = toInt64(v)
+		case "rootError":
+			e.RootError = errors.New(toString(v))
+		}
+	}
+	if len(p.Instance) > 0 {
+		details["instance"] = p.Instance
+	}
+	if len(p.Title) > 0 && p.Title != http.StatusText(e.StatusCode) && p.Title != e.Error() {
+		details["title"] = p.Title
+	}
+	if len(details) > 0 {
+		e.Details = details
+	}
+	return e
+}
+
+// FromMap is the inverse of MakeMap.
+func FromMap(mp map[string]interface{}, prefix string) *HttpError {
+	e := &HttpError{}
+	for k, v := range mp {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		switch k[len(prefix):] {
+		case "Method":
+			e.Method = toString(v)
+		case "Duration":
+			e.Duration = toInt64(v)
+		case "Status":
+			e.StatusCode = int(toInt64(v))
+		case "Request":
+			e.Request = toString(v)
+		case "Response":
+			e.Response = toString(v)
+		case "Url":
+			e.Url = toString(v)
+		case "Error":
+			e.ErrorMessage = toString(v)
+		case "RootError":
+			e.RootError = errors.New(toString(v))
+		case "ErrorType":
+			e.ErrorType = toString(v)
+		case "ErrorCode":
+			e.ErrorCode = toString(v)
+		case "Service":
+			e.Service = toString(v)
+		case "Severity":
+			e.Severity = toString(v)
+		case "Details":
+			if d, ok := v.(map[string]interface{}); ok {
+				e.Details = d
+			}
+		}
+	}
+	return e
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
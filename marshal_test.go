@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var httpErrorTests = []struct {
+	name string
+	e    *HttpError
+}{
+	{"status", &HttpError{StatusCode: 502, ErrorMessage: "bad gateway", Url: "http://x", Duration: 12}},
+	{"method", &HttpError{Method: "POST", StatusCode: 400, ErrorMessage: "invalid", Url: "http://x/users", Request: `{"id":"1"}`, Response: `{"error":"invalid"}`, Duration: 5}},
+	{"root error", &HttpError{RootError: errors.New("connection refused"), ErrorType: "network"}},
+	{"root error and message", &HttpError{StatusCode: 500, ErrorMessage: "failed", RootError: errors.New("EOF")}},
+	{"classified", &HttpError{StatusCode: 500, ErrorCode: "E1", Service: "billing", Severity: "high", Details: map[string]interface{}{"a": "b"}}},
+	{"title", &HttpError{StatusCode: 403, ErrorType: "urn:out-of-credit", Details: map[string]interface{}{"title": "Out of credit", "instance": "/account/1"}}},
+	{"status only", &HttpError{StatusCode: 404}},
+}
+
+func sameHttpError(t *testing.T, name string, got *HttpError, want *HttpError) {
+	if got.GetRootError() != want.GetRootError() {
+		t.Errorf("%s: root error %q, want %q", name, got.GetRootError(), want.GetRootError())
+	}
+	g, w := *got, *want
+	g.RootError, w.RootError = nil, nil
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: round trip = %+v, want %+v", name, g, w)
+	}
+}
+
+func TestHttpErrorJSON(t *testing.T) {
+	for _, tt := range httpErrorTests {
+		b, err := json.Marshal(tt.e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e HttpError
+		if err := json.Unmarshal(b, &e); err != nil {
+			t.Fatal(err)
+		}
+		sameHttpError(t, tt.name, &e, tt.e)
+	}
+}
+
+func TestHttpErrorProblem(t *testing.T) {
+	for _, tt := range httpErrorTests {
+		b, err := json.Marshal(tt.e.ToProblem())
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := ParseHttpError(ProblemContentType, b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		sameHttpError(t, tt.name, e, tt.e)
+	}
+}
+
+func TestHttpErrorMap(t *testing.T) {
+	for _, tt := range httpErrorTests {
+		sameHttpError(t, tt.name, FromMap(MakeMap(tt.e, "upstream"), "upstream"), tt.e)
+	}
+}
+
+func TestMarshalErrorAndParse(t *testing.T) {
+	e := &HttpError{StatusCode: 503, ErrorMessage: "busy", ErrorType: "urn:busy", ErrorCode: "B1", Url: "http://x/a", Request: "{}", Details: map[string]interface{}{"retryAfter": float64(3)}}
+	tests := []struct {
+		name        string
+		c           *ErrorConfig
+		contentType string
+		url         string
+	}{
+		{"json", nil, "application/json", ""},
+		{"problem", &ErrorConfig{Problem: true, Url: true}, ProblemContentType, "http://x/a"},
+	}
+	for _, tt := range tests {
+		contentType, b, err := MarshalError(e, tt.c)
+		if err != nil || contentType != tt.contentType {
+			t.Fatalf("%s: MarshalError = %s, %v", tt.name, contentType, err)
+		}
+		e2, err := ParseHttpError(contentType, b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if e2.StatusCode != 503 || e2.ErrorType != "urn:busy" || e2.ErrorCode != "B1" || e2.ErrorMessage != "busy" || e2.Url != tt.url || len(e2.Request) > 0 {
+			t.Errorf("%s: ParseHttpError = %+v", tt.name, e2)
+		}
+		if !reflect.DeepEqual(e2.Details, e.Details) {
+			t.Errorf("%s: the details must be kept as is, got %v", tt.name, e2.Details)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Metric struct {
+	Method       string
+	Host         string
+	Route        string
+	Status       int
+	StatusClass  string
+	ErrorType    string
+	Attempts     int
+	RequestSize  int64
+	ResponseSize int64
+	Latency      time.Duration
+}
+
+// Metrics is invoked once per attempt by DoAndLog, DoAndBuildDecoder and DoAndLogCommon, when the response body is closed. Set it into LogConfig.Metrics.
+// Latency is the time from the start of the call to the response headers returned by the transport, so it does not include the read of the body by the interceptors,
+// nor the logs. ResponseSize is the number of bytes of the body read by the caller.
+type Metrics interface {
+	Observe(ctx context.Context, m Metric)
+}
+
+type MetricsFunc func(ctx context.Context, m Metric)
+
+func (f MetricsFunc) Observe(ctx context.Context, m Metric) {
+	f(ctx, m)
+}
+
+type routeKey struct{}
+type attemptKey struct{}
+
+// WithRoute sets the route template of the call, such as "/users/{id}", to keep the cardinality of the metrics low.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+func GetRoute(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	s, _ := ctx.Value(routeKey{}).(string)
+	return s
+}
+
+// WithAttempt sets the attempt number of the call, when the call is retried.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+func GetAttempt(ctx context.Context) int {
+	if ctx != nil {
+		if n, ok := ctx.Value(attemptKey{}).(int); ok && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+func StatusClass(status int) string {
+	if status <= 0 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Observe builds the metric of a call and passes it to conf.Metrics.
+// If the call has a response body, the metric is passed when the body is closed, with the number of bytes read as ResponseSize,
+// because ContentLength is -1 for a chunked response.
+func Observe(ctx context.Context, conf *LogConfig, rec *HeaderRecorder, latency time.Duration, method string, u string, body []byte, res *http.Response, err error) {
+	if conf == nil || conf.Metrics == nil {
+		return
+	}
+	status, _ := rec.Status()
+	m := Metric{Method: method, Route: GetRoute(ctx), Attempts: GetAttempt(ctx), RequestSize: int64(len(body)), Latency: latency}
+	if x, er1 := url.Parse(u); er1 == nil {
+		m.Host = x.Host
+	}
+	if err != nil {
+		if e, ok := IsHttpError(err); ok {
+			m.ErrorType = e.ErrorType
+			if status == 0 {
+				status = e.StatusCode
+			}
+			if len(m.ErrorType) == 0 && e.StatusCode == 0 {
+				m.ErrorType = NetworkKind(e.RootError)
+			}
+		} else {
+			m.ErrorType = NetworkKind(err)
+		}
+	}
+	m.Status = status
+	m.StatusClass = StatusClass(status)
+	if res == nil || res.Body == nil || res.Body == http.NoBody {
+		conf.Metrics.Observe(ctx, m)
+		return
+	}
+	res.Body = &countingBody{ReadCloser: res.Body, observe: func(n int64) {
+		m.ResponseSize = n
+		conf.Metrics.Observe(ctx, m)
+	}}
+}
+
+// countingBody counts the bytes read from the response body, and passes the count to observe when the body is closed.
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	once    sync.Once
+	observe func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.observe(b.n) })
+	return err
+}
+
+// ExpvarMetrics publishes the counters per host, method and route into an expvar.Map, exposed by expvar.Handler() at /debug/vars.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+	mu   sync.Mutex
+}
+
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	if len(name) == 0 {
+		name = "http_client"
+	}
+	v, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		v = expvar.NewMap(name)
+	}
+	return &ExpvarMetrics{vars: v}
+}
+func (e *ExpvarMetrics) Observe(ctx context.Context, m Metric) {
+	key := m.Host + " " + m.Method
+	if len(m.Route) > 0 {
+		key = key + " " + m.Route
+	}
+	e.mu.Lock()
+	v, ok := e.vars.Get(key).(*expvar.Map)
+	if !ok {
+		v = new(expvar.Map).Init()
+		e.vars.Set(key, v)
+	}
+	e.mu.Unlock()
+	v.Add("count", 1)
+	v.Add(m.StatusClass, 1)
+	if len(m.ErrorType) > 0 {
+		v.Add("errors", 1)
+		v.Add("error_"+m.ErrorType, 1)
+	}
+	if m.Attempts > 1 {
+		v.Add("retries", 1)
+	}
+	v.Add("latency_ms", m.Latency.Milliseconds())
+	v.Add("request_bytes", m.RequestSize)
+	if m.ResponseSize > 0 {
+		v.Add("response_bytes", m.ResponseSize)
+	}
+}
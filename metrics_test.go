@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsCountRetriesAndBytesRead(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		// chunked: ContentLength is -1
+		for i := 0; i < 4; i++ {
+			w.Write([]byte(strings.Repeat("a", 256)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var metrics []Metric
+	conf := &LogConfig{Metrics: MetricsFunc(func(ctx context.Context, m Metric) {
+		mu.Lock()
+		defer mu.Unlock()
+		metrics = append(metrics, m)
+	})}
+	c := &Client{Client: srv.Client(), Url: srv.URL, Config: conf}
+	if _, err := c.R(context.Background()).Path("/data").Expect(http.StatusOK).Retry(2, 0).Get(); err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("expected a metric per attempt, got %d", len(metrics))
+	}
+	tests := []struct {
+		attempts int
+		class    string
+		size     int64
+	}{
+		{1, "5xx", 0},
+		{2, "2xx", 1024},
+	}
+	for i, tt := range tests {
+		m := metrics[i]
+		if m.Attempts != tt.attempts || m.StatusClass != tt.class || m.ResponseSize != tt.size {
+			t.Errorf("metric %d = %+v, want attempts %d, status %s, size %d", i, m, tt.attempts, tt.class, tt.size)
+		}
+	}
+
+	e := NewExpvarMetrics("test_http_client")
+	for _, m := range metrics {
+		e.Observe(context.Background(), m)
+	}
+	if v := e.vars.String(); !strings.Contains(v, `"retries": 1`) || !strings.Contains(v, `"response_bytes": 1024`) {
+		t.Errorf("unexpected counters %s", v)
+	}
+}
+
+func TestMetricsLatencyIsTimeToHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	var latency time.Duration
+	var observed bool
+	conf := &LogConfig{Log: true, Duration: "duration", Response: "response", Metrics: MetricsFunc(func(ctx context.Context, m Metric) {
+		latency, observed = m.Latency, true
+	})}
+	slowLog := func(ctx context.Context, msg string, fields map[string]interface{}) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	start := time.Now()
+	res, err := DoAndLog(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, conf, slowLog, slowLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res.Body)
+	total := time.Since(start)
+	if !observed {
+		t.Fatal("the metric must be observed when the body is closed")
+	}
+	if latency <= 0 || latency >= 200*time.Millisecond || total < 200*time.Millisecond {
+		t.Errorf("the latency must not include the logs, latency %v, total %v", latency, total)
+	}
+}
@@ -0,0 +1,126 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/core-go/client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "github.com/core-go/client/otel"
+
+// Tracer implements client.Tracer with OpenTelemetry. It creates a client span per call, with the http semantic convention attributes.
+type Tracer struct {
+	Tracer trace.Tracer
+}
+
+// NewTracer creates a Tracer from the provider. If provider is nil, the global provider is used.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{Tracer: provider.Tracer(name)}
+}
+
+// Start starts a client span; the url is already redacted by the client (the query parameters of the redact config are masked), and its user info is removed.
+func (t *Tracer) Start(ctx context.Context, method string, rawUrl string) (context.Context, func(int, error)) {
+	attrs := []attribute.KeyValue{attribute.String("http.request.method", method)}
+	if u, err := url.Parse(rawUrl); err == nil {
+		u.User = nil
+		attrs = append(attrs, attribute.String("url.full", u.String()), attribute.String("server.address", u.Hostname()))
+		if port := serverPort(u); port > 0 {
+			attrs = append(attrs, attribute.Int("server.port", port))
+		}
+	}
+	ctx, span := t.Tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	ctx = client.WithSpanContext(ctx, SpanContext(span.SpanContext()))
+	return ctx, func(status int, err error) {
+		defer span.End()
+		if status > 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+		}
+		if err == nil {
+			if status >= 400 {
+				span.SetAttributes(attribute.String("error.type", strconv.Itoa(status)))
+				span.SetStatus(codes.Error, "")
+			}
+			return
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		e, ok := client.IsHttpError(err)
+		if !ok {
+			span.SetAttributes(attribute.String("error.type", "_OTHER"))
+			return
+		}
+		errorType := e.ErrorType
+		if len(errorType) == 0 {
+			errorType = strconv.Itoa(e.StatusCode)
+		}
+		span.SetAttributes(attribute.String("error.type", errorType))
+		if len(e.ErrorCode) > 0 {
+			span.SetAttributes(attribute.String("error.code", e.ErrorCode))
+		}
+		if len(e.Service) > 0 {
+			span.SetAttributes(attribute.String("peer.service", e.Service))
+		}
+	}
+}
+
+// SpanContext converts the OpenTelemetry span context to client.SpanContext.
+func SpanContext(sc trace.SpanContext) client.SpanContext {
+	return client.SpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		Flags:      byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+	}
+}
+
+// WithSpanContext puts the span context of the current OpenTelemetry span into ctx, so that the trace id and span id are propagated and logged without Tracer.
+func WithSpanContext(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return client.WithSpanContext(ctx, SpanContext(sc))
+}
+
+// Propagator implements client.Propagator by the OpenTelemetry text map propagator, such as client.DefaultPropagator = otel.NewPropagator(nil).
+type Propagator struct {
+	Propagator propagation.TextMapPropagator
+}
+
+// NewPropagator creates a Propagator. If p is nil, the global propagator is used.
+func NewPropagator(p propagation.TextMapPropagator) *Propagator {
+	return &Propagator{Propagator: p}
+}
+
+func (p *Propagator) Inject(ctx context.Context, header http.Header) {
+	tp := p.Propagator
+	if tp == nil {
+		tp = otel.GetTextMapPropagator()
+	}
+	tp.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+func serverPort(u *url.URL) int {
+	if p := u.Port(); len(p) > 0 {
+		port, _ := strconv.Atoi(p)
+		return port
+	}
+	switch u.Scheme {
+	case "https":
+		return 443
+	case "http":
+		return 80
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+type ProblemConfig struct {
+	ContentTypes []string `yaml:"content_types" mapstructure:"content_types" json:"contentTypes,omitempty" gorm:"column:contenttypes" bson:"contentTypes,omitempty" dynamodbav:"contentTypes,omitempty" firestore:"contentTypes,omitempty"`
+	Type         string   `yaml:"type" mapstructure:"type" json:"type,omitempty" gorm:"column:type" bson:"type,omitempty" dynamodbav:"type,omitempty" firestore:"type,omitempty"`
+	Title        string   `yaml:"title" mapstructure:"title" json:"title,omitempty" gorm:"column:title" bson:"title,omitempty" dynamodbav:"title,omitempty" firestore:"title,omitempty"`
+	Status       string   `yaml:"status" mapstructure:"status" json:"status,omitempty" gorm:"column:status" bson:"status,omitempty" dynamodbav:"status,omitempty" firestore:"status,omitempty"`
+	Detail       string   `yaml:"detail" mapstructure:"detail" json:"detail,omitempty" gorm:"column:detail" bson:"detail,omitempty" dynamodbav:"detail,omitempty" firestore:"detail,omitempty"`
+	Instance     string   `yaml:"instance" mapstructure:"instance" json:"instance,omitempty" gorm:"column:instance" bson:"instance,omitempty" dynamodbav:"instance,omitempty" firestore:"instance,omitempty"`
+	Code         string   `yaml:"code" mapstructure:"code" json:"code,omitempty" gorm:"column:code" bson:"code,omitempty" dynamodbav:"code,omitempty" firestore:"code,omitempty"`
+}
+
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func InitializeProblem(c *ProblemConfig) *ProblemConfig {
+	var c2 ProblemConfig
+	if c != nil {
+		c2 = *c
+	}
+	if len(c2.Type) == 0 {
+		c2.Type = "type"
+	}
+	if len(c2.Title) == 0 {
+		c2.Title = "title"
+	}
+	if len(c2.Status) == 0 {
+		c2.Status = "status"
+	}
+	if len(c2.Detail) == 0 {
+		c2.Detail = "detail"
+	}
+	if len(c2.Instance) == 0 {
+		c2.Instance = "instance"
+	}
+	if len(c2.Code) == 0 {
+		c2.Code = "code"
+	}
+	return &c2
+}
+
+// IsProblem reports whether the response body should be decoded as a problem document: either the content type is application/problem+json,
+// or it is one of the configured content types.
+func IsProblem(contentType string, c *ProblemConfig) bool {
+	if len(contentType) == 0 {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if mt == ProblemContentType {
+		return true
+	}
+	if c != nil {
+		for _, t := range c.ContentTypes {
+			if strings.EqualFold(t, mt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ParseProblem(body []byte, c *ProblemConfig) (*Problem, bool) {
+	if len(body) == 0 {
+		return nil, false
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, false
+	}
+	c = InitializeProblem(c)
+	p := &Problem{Extensions: make(map[string]interface{})}
+	matched := false
+	for k, v := range m {
+		switch k {
+		case c.Type:
+			p.Type, matched = toString(v), true
+		case c.Title:
+			p.Title, matched = toString(v), true
+		case c.Detail:
+			p.Detail, matched = toString(v), true
+		case c.Instance:
+			p.Instance = toString(v)
+		case c.Status:
+			if f, ok := v.(float64); ok {
+				p.Status = int(f)
+			}
+		default:
+			p.Extensions[k] = v
+		}
+	}
+	return p, matched
+}
+
+// SetProblem decodes the response body into err when the content type says it is a problem document.
+// Type, code, message are mapped into ErrorType, ErrorCode and ErrorMessage; all members are kept in Details.
+func SetProblem(err *HttpError, contentType string, body []byte, c *ProblemConfig) bool {
+	if err == nil || !IsProblem(contentType, c) {
+		return false
+	}
+	p, ok := ParseProblem(body, c)
+	if !ok {
+		return false
+	}
+	setProblem(err, p, InitializeProblem(c))
+	return true
+}
+func setProblem(err *HttpError, p *Problem, c *ProblemConfig) {
+	if len(p.Type) > 0 && p.Type != "about:blank" {
+		err.ErrorType = p.Type
+	}
+	if v, ok := p.Extensions[c.Code]; ok {
+		err.ErrorCode = toString(v)
+	} else if len(p.Title) > 0 {
+		err.ErrorCode = p.Title
+	}
+	if len(p.Detail) > 0 {
+		err.ErrorMessage = p.Detail
+	} else if len(p.Title) > 0 {
+		err.ErrorMessage = p.Title
+	}
+	if v, ok := p.Extensions["service"]; ok {
+		err.Service = toString(v)
+	}
+	if v, ok := p.Extensions["severity"]; ok {
+		err.Severity = toString(v)
+	}
+	err.Details = p.Map()
+}
+
+func (p *Problem) Map() map[string]interface{} {
+	mp := make(map[string]interface{})
+	for k, v := range p.Extensions {
+		mp[k] = v
+	}
+	if len(p.Type) > 0 {
+		mp["type"] = p.Type
+	}
+	if len(p.Title) > 0 {
+		mp["title"] = p.Title
+	}
+	if p.Status > 0 {
+		mp["status"] = p.Status
+	}
+	if len(p.Detail) > 0 {
+		mp["detail"] = p.Detail
+	}
+	if len(p.Instance) > 0 {
+		mp["instance"] = p.Instance
+	}
+	return mp
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		if s == float64(int64(s)) {
+			return fmt.Sprint(int64(s))
+		}
+		return fmt.Sprint(s)
+	default:
+		return fmt.Sprint(s)
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServiceUnavailableProblem(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.error+json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"type":"urn:busy","title":"Busy","detail":"try later","code":"B1"}`))
+	}))
+	defer srv.Close()
+
+	conf := &LogConfig{Problem: &ProblemConfig{ContentTypes: []string{"application/vnd.error+json"}}}
+	res, err := DoAndLog(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, conf)
+	if res != nil {
+		DrainAndClose(res.Body)
+	}
+	e, ok := IsHttpError(err)
+	if !ok {
+		t.Fatalf("expected HttpError, got %v", err)
+	}
+	if e.StatusCode != http.StatusServiceUnavailable || e.ErrorType != "urn:busy" || e.ErrorCode != "B1" || e.ErrorMessage != "try later" {
+		t.Errorf("the problem of the configured content type must be decoded, got %+v", e)
+	}
+}
+
+func TestIsProblem(t *testing.T) {
+	c := &ProblemConfig{ContentTypes: []string{"application/vnd.error+json"}}
+	tests := []struct {
+		contentType string
+		c           *ProblemConfig
+		want        bool
+	}{
+		{"application/problem+json", nil, true},
+		{"application/problem+json; charset=utf-8", nil, true},
+		{"application/json", nil, false},
+		{"application/vnd.error+json", nil, false},
+		{"application/vnd.error+json", c, true},
+		{"", c, false},
+	}
+	for _, tt := range tests {
+		if got := IsProblem(tt.contentType, tt.c); got != tt.want {
+			t.Errorf("IsProblem(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestSetProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		c       *ProblemConfig
+		ok      bool
+		errType string
+		code    string
+		message string
+	}{
+		{"full", `{"type":"urn:out-of-credit","title":"Out of credit","status":403,"detail":"balance is 30","code":"C1","service":"billing"}`, nil, true, "urn:out-of-credit", "C1", "balance is 30"},
+		{"title only", `{"type":"about:blank","title":"Not Found"}`, nil, true, "", "Not Found", "Not Found"},
+		{"custom members", `{"kind":"urn:x","message":"failed","errorCode":"E1"}`, &ProblemConfig{Type: "kind", Detail: "message", Code: "errorCode"}, true, "urn:x", "E1", "failed"},
+		{"no member", `{"a":1}`, nil, false, "", "", "original"},
+		{"not json", `oops`, nil, false, "", "", "original"},
+	}
+	for _, tt := range tests {
+		e := &HttpError{StatusCode: 403, ErrorMessage: "original"}
+		ok := SetProblem(e, ProblemContentType, []byte(tt.body), tt.c)
+		if ok != tt.ok || e.ErrorType != tt.errType || e.ErrorCode != tt.code || e.ErrorMessage != tt.message {
+			t.Errorf("%s: SetProblem = %v, %+v", tt.name, ok, e)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/core-go/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var labels = []string{"method", "host", "route", "status_class", "error_type"}
+
+// Metrics implements client.Metrics with Prometheus histograms and counters.
+type Metrics struct {
+	Registry     *prometheus.Registry
+	Requests     *prometheus.CounterVec
+	Retries      *prometheus.CounterVec
+	Latency      *prometheus.HistogramVec
+	RequestSize  *prometheus.HistogramVec
+	ResponseSize *prometheus.HistogramVec
+}
+
+// NewMetrics creates and registers the collectors. If registry is nil, a new registry is created, to be exposed by Handler.
+func NewMetrics(namespace string, registry *prometheus.Registry, buckets ...float64) (*Metrics, error) {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	sizes := prometheus.ExponentialBuckets(128, 4, 8)
+	m := &Metrics{
+		Registry: registry,
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http_client", Name: "requests_total", Help: "Number of outbound http requests.",
+		}, labels),
+		Retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http_client", Name: "retries_total", Help: "Number of retried outbound http requests.",
+		}, labels),
+		Latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http_client", Name: "request_duration_seconds", Help: "Latency of outbound http requests.", Buckets: buckets,
+		}, labels),
+		RequestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http_client", Name: "request_size_bytes", Help: "Size of outbound http request bodies.", Buckets: sizes,
+		}, labels),
+		ResponseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http_client", Name: "response_size_bytes", Help: "Size of http response bodies.", Buckets: sizes,
+		}, labels),
+	}
+	for _, c := range []prometheus.Collector{m.Requests, m.Retries, m.Latency, m.RequestSize, m.ResponseSize} {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) Observe(ctx context.Context, metric client.Metric) {
+	values := []string{metric.Method, metric.Host, metric.Route, metric.StatusClass, metric.ErrorType}
+	m.Requests.WithLabelValues(values...).Inc()
+	if metric.Attempts > 1 {
+		m.Retries.WithLabelValues(values...).Inc()
+	}
+	m.Latency.WithLabelValues(values...).Observe(metric.Latency.Seconds())
+	m.RequestSize.WithLabelValues(values...).Observe(float64(metric.RequestSize))
+	m.ResponseSize.WithLabelValues(values...).Observe(float64(metric.ResponseSize))
+}
+
+// Handler exposes the registry, to be served on a local port such as http.ListenAndServe("127.0.0.1:9090", m.Handler()).
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	RequestIdHeader     = "X-Request-Id"
+	CorrelationIdHeader = "X-Correlation-Id"
+)
+
+// DefaultPropagateHeaders is the allowlist of the inbound headers to be forwarded to the outbound calls, if Capture is called without headers.
+var DefaultPropagateHeaders = []string{RequestIdHeader, CorrelationIdHeader, "Accept-Language", "X-Tenant-Id", "X-User-Id"}
+
+type propagateKey struct{}
+
+// WithHeaders puts the headers to be forwarded to the outbound calls into the context.
+func WithHeaders(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, propagateKey{}, header)
+}
+func GetHeaders(ctx context.Context) http.Header {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(propagateKey{}).(http.Header)
+	return h
+}
+func GetRequestId(ctx context.Context) string {
+	return GetHeaders(ctx).Get(RequestIdHeader)
+}
+
+// EnsureRequestId returns the context with a generated request id, if the context has no request id.
+func EnsureRequestId(ctx context.Context) context.Context {
+	if ctx == nil || len(GetRequestId(ctx)) > 0 {
+		return ctx
+	}
+	h := GetHeaders(ctx).Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Set(RequestIdHeader, NewRequestId())
+	return WithHeaders(ctx, h)
+}
+
+func NewRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Capture is an http middleware to capture the allowlist of headers (DefaultPropagateHeaders by default) of the inbound request into the context.
+// A request id is generated if the inbound request has none. The trace context of the inbound request is extracted as well.
+func Capture(headers ...string) func(http.Handler) http.Handler {
+	if len(headers) == 0 {
+		headers = DefaultPropagateHeaders
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := make(http.Header)
+			for _, k := range headers {
+				if v := r.Header.Values(k); len(v) > 0 {
+					h[http.CanonicalHeaderKey(k)] = v
+				}
+			}
+			ctx := EnsureRequestId(WithHeaders(Extract(r.Context(), r.Header), h))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Propagate sets the captured headers of the context into the outbound request, without overriding the headers set by the caller.
+func Propagate(ctx context.Context, header http.Header) {
+	if ctx == nil {
+		return
+	}
+	ctx = EnsureRequestId(ctx)
+	for k, v := range GetHeaders(ctx) {
+		if len(header.Values(k)) == 0 {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+}
+
+// LogRequestId wraps a log function to add the request id of the context into the request_id field.
+func LogRequestId(conf *LogConfig, options []func(context.Context, string, map[string]interface{})) []func(context.Context, string, map[string]interface{}) {
+	if conf == nil || len(conf.RequestId) == 0 {
+		return options
+	}
+	return wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return func(ctx context.Context, msg string, fields map[string]interface{}) {
+			if id := GetRequestId(ctx); len(id) > 0 {
+				fields[conf.RequestId] = id
+			}
+			log(ctx, msg, fields)
+		}
+	})
+}
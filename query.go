@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryOptions are the defaults of EncodeQuery; the options of the url tag override them per field.
+type QueryOptions struct {
+	Dot        bool   // nested keys as "a.b" instead of "a[b]"
+	Comma      bool   // slices as "a=1,2" instead of "a=1&a=2"
+	TimeLayout string // time.RFC3339 by default
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// EncodeQuery encodes a struct (or a map) into url.Values, by the url tag: `url:"name,omitempty"`. The options of the tag are:
+//   - omitempty: skip the zero value
+//   - comma: join the slice with comma; brackets: repeat the slice as "name[]"
+//   - dot, bracket: the notation of the nested struct or map
+//   - unix, unixmilli: the time as a unix timestamp; the layout tag sets the time layout, such as `layout:"2006-01-02"`
+//
+// A field with the "-" tag is skipped; the fields of an embedded struct without tag are promoted.
+func EncodeQuery(v interface{}, options ...QueryOptions) (url.Values, error) {
+	var o QueryOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+	if len(o.TimeLayout) == 0 {
+		o.TimeLayout = time.RFC3339
+	}
+	values := make(url.Values)
+	if v == nil {
+		return values, nil
+	}
+	if uv, ok := v.(url.Values); ok {
+		for k, x := range uv {
+			values[k] = append(values[k], x...)
+		}
+		return values, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return values, encodeStruct(values, "", rv, o)
+	case reflect.Map:
+		return values, encodeMap(values, "", rv, o)
+	}
+	return nil, fmt.Errorf("query: cannot encode %s, a struct or a map is expected", rv.Type())
+}
+
+// AppendQuery appends the encoded query of v to the url.
+func AppendQuery(u string, v interface{}, options ...QueryOptions) (string, error) {
+	values, err := EncodeQuery(v, options...)
+	if err != nil || len(values) == 0 {
+		return u, err
+	}
+	if strings.Contains(u, "?") {
+		return u + "&" + values.Encode(), nil
+	}
+	return u + "?" + values.Encode(), nil
+}
+
+type queryField struct {
+	name      string
+	omitEmpty bool
+	opts      QueryOptions
+	unix      string
+	brackets  bool
+}
+
+func parseQueryTag(f reflect.StructField, o QueryOptions) (queryField, bool) {
+	tag := f.Tag.Get("url")
+	if tag == "-" {
+		return queryField{}, false
+	}
+	qf := queryField{name: f.Name, opts: o}
+	parts := strings.Split(tag, ",")
+	if len(parts[0]) > 0 {
+		qf.name = parts[0]
+	}
+	for _, p := range parts[1:] {
+		switch p {
+		case "omitempty":
+			qf.omitEmpty = true
+		case "comma":
+			qf.opts.Comma = true
+		case "brackets":
+			qf.brackets = true
+		case "dot":
+			qf.opts.Dot = true
+		case "bracket":
+			qf.opts.Dot = false
+		case "unix", "unixmilli":
+			qf.unix = p
+		}
+	}
+	if layout := f.Tag.Get("layout"); len(layout) > 0 {
+		qf.opts.TimeLayout = layout
+	}
+	return qf, true
+}
+
+func encodeStruct(values url.Values, prefix string, rv reflect.Value, o QueryOptions) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 && !f.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		if f.Anonymous && len(f.Tag.Get("url")) == 0 {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := encodeStruct(values, prefix, fv, o); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		qf, ok := parseQueryTag(f, o)
+		if !ok {
+			continue
+		}
+		if err := encodeValue(values, queryKey(prefix, qf.name, o), fv, qf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeMap(values url.Values, prefix string, rv reflect.Value, o QueryOptions) error {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	for _, k := range keys {
+		qf := queryField{name: fmt.Sprint(k.Interface()), opts: o}
+		if err := encodeValue(values, queryKey(prefix, qf.name, o), rv.MapIndex(k), qf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeValue(values url.Values, name string, v reflect.Value, qf queryField) error {
+	if qf.omitEmpty && isEmptyValue(v) {
+		return nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			values.Add(name, "")
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		values.Add(name, formatTime(v.Interface().(time.Time), qf))
+		return nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		values.Add(name, string(b))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return encodeStruct(values, name, v, qf.opts)
+	case reflect.Map:
+		return encodeMap(values, name, v, qf.opts)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(name, string(v.Bytes()))
+			return nil
+		}
+		if qf.brackets {
+			name = name + "[]"
+		}
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := formatScalar(v.Index(i), qf)
+			if err != nil {
+				return err
+			}
+			items = append(items, s)
+		}
+		if qf.opts.Comma {
+			values.Add(name, strings.Join(items, ","))
+		} else {
+			for _, s := range items {
+				values.Add(name, s)
+			}
+		}
+		return nil
+	}
+	s, err := formatScalar(v, qf)
+	if err != nil {
+		return err
+	}
+	values.Add(name, s)
+	return nil
+}
+
+func formatScalar(v reflect.Value, qf queryField) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return formatTime(v.Interface().(time.Time), qf), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("query: cannot encode %s as a query value", v.Type())
+}
+
+func formatTime(t time.Time, qf queryField) string {
+	switch qf.unix {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(qf.opts.TimeLayout)
+}
+
+func queryKey(prefix string, name string, o QueryOptions) string {
+	if len(prefix) == 0 {
+		return name
+	}
+	if o.Dot {
+		return prefix + "." + name
+	}
+	return prefix + "[" + name + "]"
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+		return v.IsZero()
+	}
+	return false
+}
+
+func GetWithQuery(ctx context.Context, client Doer, url string, query interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	u, err := AppendQuery(url, query)
+	if err != nil {
+		return err
+	}
+	return Get(ctx, client, u, result, conf, options...)
+}
+func DeleteWithQuery(ctx context.Context, client Doer, url string, query interface{}, result interface{}, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) error {
+	u, err := AppendQuery(url, query)
+	if err != nil {
+		return err
+	}
+	return Delete(ctx, client, u, result, conf, options...)
+}
+
+// QueryStruct adds the query parameters encoded from v by EncodeQuery.
+func (r *Request) QueryStruct(v interface{}, options ...QueryOptions) *Request {
+	values, err := EncodeQuery(v, options...)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	return r.QueryValues(values)
+}
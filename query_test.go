@@ -0,0 +1,85 @@
+package client
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+type queryPage struct {
+	Page  int `url:"page,omitempty"`
+	Limit int `url:"limit"`
+}
+
+type querySearch struct {
+	queryPage
+	Q       string            `url:"q"`
+	Tags    []string          `url:"tags"`
+	Ids     []int             `url:"ids,comma"`
+	Roles   []string          `url:"roles,brackets"`
+	Since   time.Time         `url:"since,omitempty"`
+	Day     time.Time         `url:"day,omitempty" layout:"2006-01-02"`
+	At      time.Time         `url:"at,unix,omitempty"`
+	Filter  *queryFilter      `url:"filter,omitempty"`
+	Meta    map[string]string `url:"meta,dot,omitempty"`
+	Skip    string            `url:"-"`
+	Pointer *string           `url:"pointer,omitempty"`
+	secret  string
+}
+
+type queryFilter struct {
+	Status string `url:"status"`
+	Min    int    `url:"min,omitempty"`
+}
+
+func TestEncodeQuery(t *testing.T) {
+	at := time.Date(2024, 10, 19, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		v       interface{}
+		options []QueryOptions
+		want    string
+	}{
+		{"nil", nil, nil, ""},
+		{"values", url.Values{"a": {"1", "2"}}, nil, "a=1&a=2"},
+		{"map", map[string]interface{}{"b": 2, "a": "x"}, nil, "a=x&b=2"},
+		{"omitempty and embedded", querySearch{Q: "go"}, nil, "ids=&limit=0&q=go"},
+		{"embedded", querySearch{queryPage: queryPage{Page: 2, Limit: 10}}, nil, "ids=&limit=10&page=2&q="},
+		{"slices", querySearch{Tags: []string{"a", "b"}, Ids: []int{1, 2}, Roles: []string{"x"}}, nil, "ids=1%2C2&limit=0&q=&roles%5B%5D=x&tags=a&tags=b"},
+		{"times", querySearch{Since: at, Day: at, At: at}, nil, "at=1729324800&day=2024-10-19&ids=&limit=0&q=&since=2024-10-19T08%3A00%3A00Z"},
+		{"nested bracket", querySearch{Filter: &queryFilter{Status: "open"}}, nil, "filter%5Bstatus%5D=open&ids=&limit=0&q="},
+		{"nested dot", querySearch{Filter: &queryFilter{Status: "open", Min: 1}}, []QueryOptions{{Dot: true}}, "filter.min=1&filter.status=open&ids=&limit=0&q="},
+		{"map dot tag", querySearch{Meta: map[string]string{"k": "v"}}, nil, "ids=&limit=0&meta.k=v&q="},
+		{"comma option", map[string][]int{"n": {1, 2}}, []QueryOptions{{Comma: true}}, "n=1%2C2"},
+	}
+	for _, tt := range tests {
+		values, err := EncodeQuery(tt.v, tt.options...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := values.Encode(); got != tt.want {
+			t.Errorf("%s: EncodeQuery = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := EncodeQuery(1); err == nil {
+		t.Errorf("a scalar must not be encoded")
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	tests := []struct {
+		url  string
+		v    interface{}
+		want string
+	}{
+		{"http://x/a", map[string]int{"p": 1}, "http://x/a?p=1"},
+		{"http://x/a?q=1", map[string]int{"p": 1}, "http://x/a?q=1&p=1"},
+		{"http://x/a", map[string]int{}, "http://x/a"},
+	}
+	for _, tt := range tests {
+		if got, err := AppendQuery(tt.url, tt.v); err != nil || got != tt.want {
+			t.Errorf("AppendQuery(%s) = %s, %v, want %s", tt.url, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMask = "***"
+
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var redactPatterns = map[string]string{
+	"pan":   `\b(?:\d[ -]?){12,18}\d\b`,
+	"email": `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+}
+
+// redactValidators check the matches of the built-in patterns, so that numbers such as epoch milliseconds are not masked as card numbers.
+var redactValidators = map[string]func(string) bool{
+	"pan": IsPAN,
+}
+
+// IsPAN reports whether s (digits, with optional spaces or dashes) is a card number: 13 to 19 digits,
+// the first digit of a card network (2 to 6) and a valid Luhn check digit.
+func IsPAN(s string) bool {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i]-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 || digits[0] < 2 || digits[0] > 6 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+type redactPattern struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+type RedactConfig struct {
+	Headers  []string `yaml:"headers" mapstructure:"headers" json:"headers,omitempty" gorm:"column:headers" bson:"headers,omitempty" dynamodbav:"headers,omitempty" firestore:"headers,omitempty"`
+	Fields   []string `yaml:"fields" mapstructure:"fields" json:"fields,omitempty" gorm:"column:fields" bson:"fields,omitempty" dynamodbav:"fields,omitempty" firestore:"fields,omitempty"`
+	Query    []string `yaml:"query" mapstructure:"query" json:"query,omitempty" gorm:"column:query" bson:"query,omitempty" dynamodbav:"query,omitempty" firestore:"query,omitempty"`
+	Patterns []string `yaml:"patterns" mapstructure:"patterns" json:"patterns,omitempty" gorm:"column:patterns" bson:"patterns,omitempty" dynamodbav:"patterns,omitempty" firestore:"patterns,omitempty"`
+	Mask     string   `yaml:"mask" mapstructure:"mask" json:"mask,omitempty" gorm:"column:mask" bson:"mask,omitempty" dynamodbav:"mask,omitempty" firestore:"mask,omitempty"`
+}
+
+// Redactor masks sensitive data of url, headers and bodies before they are logged or kept in HttpError.
+type Redactor struct {
+	mask     string
+	headers  map[string]bool
+	keys     map[string]bool
+	paths    map[string]bool
+	query    map[string]bool
+	patterns []redactPattern
+}
+
+// maxRedactors is the size of the cache of GetRedactor; the cache is cleared when it is full.
+const maxRedactors = 64
+
+var redactors = struct {
+	sync.Mutex
+	m map[string]*Redactor
+}{m: make(map[string]*Redactor)}
+
+// GetRedactor returns the redactor of the config. If c is nil, only the default headers are masked.
+// The redactors are cached by the contents of the config, so that a config which is changed gets a new redactor.
+// The configs initialized by InitializeLog or stored into a DynamicLog have their own redactor, and do not use this cache.
+func GetRedactor(c *RedactConfig) *Redactor {
+	if c == nil {
+		return defaultRedactor
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return NewRedactor(*c)
+	}
+	key := string(b)
+	redactors.Lock()
+	defer redactors.Unlock()
+	if r, ok := redactors.m[key]; ok {
+		return r
+	}
+	if len(redactors.m) >= maxRedactors {
+		redactors.m = make(map[string]*Redactor)
+	}
+	r := NewRedactor(*c)
+	redactors.m[key] = r
+	return r
+}
+
+var defaultRedactor = NewRedactor(RedactConfig{})
+
+// redactorOf returns the redactor bound to the config by InitializeLog or DynamicLog, or else the redactor of conf.Redact.
+func redactorOf(conf *LogConfig) *Redactor {
+	if conf == nil {
+		return defaultRedactor
+	}
+	if conf.redactor != nil {
+		return conf.redactor
+	}
+	return GetRedactor(conf.Redact)
+}
+
+// NewRedactor builds a redactor. Patterns are either the names of the built-in patterns ("pan", "email") or regular expressions;
+// the invalid regular expressions are ignored.
+func NewRedactor(c RedactConfig) *Redactor {
+	r := &Redactor{mask: c.Mask, headers: make(map[string]bool), keys: make(map[string]bool), paths: make(map[string]bool), query: make(map[string]bool)}
+	if len(r.mask) == 0 {
+		r.mask = DefaultMask
+	}
+	headers := c.Headers
+	if len(headers) == 0 {
+		headers = DefaultRedactHeaders
+	}
+	for _, h := range headers {
+		r.headers[textproto.CanonicalMIMEHeaderKey(h)] = true
+	}
+	for _, f := range c.Fields {
+		if strings.Contains(f, ".") {
+			r.paths[f] = true
+		} else {
+			r.keys[strings.ToLower(f)] = true
+		}
+	}
+	for _, q := range c.Query {
+		r.query[q] = true
+	}
+	for _, p := range c.Patterns {
+		valid := redactValidators[strings.ToLower(p)]
+		if s, ok := redactPatterns[strings.ToLower(p)]; ok {
+			p = s
+		}
+		if re, err := regexp.Compile(p); err == nil {
+			r.patterns = append(r.patterns, redactPattern{re: re, valid: valid})
+		}
+	}
+	return r
+}
+
+func (r *Redactor) Header(key string) bool {
+	return r.headers[textproto.CanonicalMIMEHeaderKey(key)]
+}
+func (r *Redactor) Headers(h map[string][]string) map[string][]string {
+	h2 := make(map[string][]string, len(h))
+	for k, v := range h {
+		if r.Header(k) {
+			h2[k] = []string{r.mask}
+		} else {
+			h2[k] = v
+		}
+	}
+	return h2
+}
+
+// Url masks the values of the configured query parameters, keeping the order of parameters.
+func (r *Redactor) Url(u string) string {
+	if len(r.query) == 0 {
+		return u
+	}
+	i := strings.Index(u, "?")
+	if i < 0 {
+		return u
+	}
+	query := u[i+1:]
+	var fragment string
+	if j := strings.Index(query, "#"); j >= 0 {
+		query, fragment = query[:j], query[j:]
+	}
+	params := strings.Split(query, "&")
+	for k, p := range params {
+		name := p
+		if j := strings.Index(p, "="); j >= 0 {
+			name = p[:j]
+		}
+		if r.query[name] {
+			params[k] = name + "=" + r.mask
+		}
+	}
+	return u[:i+1] + strings.Join(params, "&") + fragment
+}
+
+// Body masks the configured JSON fields, then the configured patterns.
+// A truncated JSON body (cut by max_body_size) is masked token by token, so that the fields before the cut are masked too.
+func (r *Redactor) Body(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	if len(r.keys) > 0 || len(r.paths) > 0 {
+		t := strings.TrimSpace(s)
+		if len(t) > 0 && (t[0] == '{' || t[0] == '[') {
+			t, marker, _ := splitTruncated(t)
+			var v interface{}
+			d := json.NewDecoder(strings.NewReader(t))
+			d.UseNumber()
+			if err := d.Decode(&v); err == nil && !d.More() {
+				v = r.value(v, "")
+				buf := new(bytes.Buffer)
+				e := json.NewEncoder(buf)
+				e.SetEscapeHTML(false)
+				if err := e.Encode(v); err == nil {
+					s = strings.TrimSuffix(buf.String(), "\n") + marker
+				}
+			} else {
+				s = r.partial(t) + marker
+			}
+		}
+	}
+	return r.replace(s)
+}
+
+type jsonFrame struct {
+	object bool
+	n      int    // number of written members or elements
+	key    string // the key of the member, when hasKey
+	hasKey bool
+	path   string
+}
+
+// partial masks the configured fields of a JSON text which cannot be decoded, because it is truncated.
+// The tokens are written again until the end of the text; the rest of a truncated token is kept, unless it is the value of a masked field.
+// If the text is not JSON, the rest of the text from the invalid character is masked.
+func (r *Redactor) partial(s string) string {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var b strings.Builder
+	var stack []*jsonFrame
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				b.WriteString(s[d.InputOffset():])
+			} else {
+				b.WriteString(r.mask)
+			}
+			return b.String()
+		}
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			b.WriteByte(byte(delim))
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].next()
+			}
+			continue
+		}
+		path := ""
+		if top != nil {
+			path = top.path
+			if top.object && !top.hasKey {
+				key, _ := tok.(string)
+				if top.n > 0 {
+					b.WriteByte(',')
+				}
+				writeJSON(&b, key)
+				p := key
+				if len(path) > 0 {
+					p = path + "." + key
+				}
+				if r.keys[strings.ToLower(key)] || r.paths[p] {
+					b.WriteByte(':')
+					writeJSON(&b, r.mask)
+					if !skipValue(d) {
+						return b.String()
+					}
+					top.next()
+				} else {
+					top.key, top.hasKey = p, true
+				}
+				continue
+			}
+			if top.object {
+				b.WriteByte(':')
+				path = top.key
+			} else if top.n > 0 {
+				b.WriteByte(',')
+			}
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			b.WriteByte(byte(delim))
+			stack = append(stack, &jsonFrame{object: delim == '{', path: path})
+			continue
+		}
+		writeJSON(&b, tok)
+		if top != nil {
+			top.next()
+		}
+	}
+}
+func (f *jsonFrame) next() {
+	f.n++
+	f.hasKey = false
+}
+
+// skipValue reads the next value; it returns false if the value is truncated.
+func skipValue(d *json.Decoder) bool {
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return true
+		}
+	}
+}
+func writeJSON(b *strings.Builder, v interface{}) {
+	buf := new(bytes.Buffer)
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if e.Encode(v) == nil {
+		b.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+	}
+}
+
+// replace masks the matches of the configured patterns.
+func (r *Redactor) replace(s string) string {
+	for _, p := range r.patterns {
+		if p.valid == nil {
+			s = p.re.ReplaceAllString(s, r.mask)
+			continue
+		}
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.valid(m) {
+				return r.mask
+			}
+			return m
+		})
+	}
+	return s
+}
+
+// Field masks the value of a form field if its name is configured, or else the configured patterns in the value.
+func (r *Redactor) Field(name string, value string) string {
+	if r.keys[strings.ToLower(name)] || r.paths[name] {
+		return r.mask
+	}
+	return r.replace(value)
+}
+func (r *Redactor) value(v interface{}, path string) interface{} {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		for k, x := range o {
+			p := k
+			if len(path) > 0 {
+				p = path + "." + k
+			}
+			if r.keys[strings.ToLower(k)] || r.paths[p] {
+				o[k] = r.mask
+			} else {
+				o[k] = r.value(x, p)
+			}
+		}
+	case []interface{}:
+		for i, x := range o {
+			o[i] = r.value(x, path)
+		}
+	}
+	return v
+}
+
+// Dump masks a dump of http.Response (or http.Request): the header lines by name, then the body.
+func (r *Redactor) Dump(s string) string {
+	i := strings.Index(s, "\r\n\r\n")
+	if i < 0 || !strings.HasPrefix(s, "HTTP/") {
+		return r.Body(s)
+	}
+	lines := strings.Split(s[:i], "\r\n")
+	for k, line := range lines {
+		if k == 0 {
+			continue
+		}
+		if j := strings.Index(line, ":"); j > 0 && r.Header(strings.TrimSpace(line[:j])) {
+			lines[k] = line[:j] + ": " + r.mask
+		}
+	}
+	return strings.Join(lines, "\r\n") + "\r\n\r\n" + r.Body(s[i+4:])
+}
+
+func (r *Redactor) Error(e *HttpError) {
+	e.Url = r.Url(e.Url)
+	e.Request = r.Body(e.Request)
+	e.Response = r.Dump(e.Response)
+}
+
+// RedactLog wraps a log function to mask the message ("METHOD url") and the request, response fields of conf.
+func RedactLog(conf *LogConfig, log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+	if log == nil {
+		return nil
+	}
+	request, response := "request", "response"
+	if conf != nil {
+		request, response = conf.Request, conf.Response
+	}
+	r := redactorOf(conf)
+	return func(ctx context.Context, msg string, fields map[string]interface{}) {
+		if i := strings.Index(msg, " "); i > 0 {
+			msg = msg[:i+1] + r.Url(msg[i+1:])
+		}
+		if len(request) > 0 {
+			if s, ok := fields[request].(string); ok {
+				fields[request] = r.Body(s)
+			}
+		}
+		if len(response) > 0 {
+			if s, ok := fields[response].(string); ok {
+				fields[response] = r.Dump(s)
+			}
+		}
+		log(ctx, msg, fields)
+	}
+}
+
+// completeError classifies err by the rules of conf (again, if ClassifyInterceptor is in the chain, the classified fields are kept),
+// then masks the sensitive data kept in HttpError and builds its curl command.
+func completeError(conf *LogConfig, err error, start time.Time, method string, url string, b *Body, header http.Header, client Doer) error {
+	if err == nil {
+		return nil
+	}
+	var tls *Conf
+	if conf != nil {
+		err = Classify(conf.Errors, err, time.Since(start).Milliseconds(), url, b.logBytes())
+		tls = conf.TLS
+	}
+	if e, ok := IsHttpError(err); ok {
+		r := redactorOf(conf)
+		r.Error(e)
+		if len(e.Method) == 0 {
+			e.Method = method
+		}
+		rq, flags := e.Request, TLSFlags(tls, client)
+		if b.Streamed() {
+			rq, flags = "", append(b.curlFlags(r), flags...)
+		}
+		e.curl = BuildCurl(e.Method, e.Url, curlHeaders(r, header, b), rq, flags...)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRedactorBody(t *testing.T) {
+	r := NewRedactor(RedactConfig{Fields: []string{"password", "card.cvv"}, Patterns: []string{"pan", "email"}})
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"epoch millis", `{"createdAt":1729324800000}`, `{"createdAt":1729324800000}`},
+		{"pan", `{"card":"4111111111111111"}`, `{"card":"***"}`},
+		{"pan with spaces", "card 4111 1111 1111 1111 ok", "card *** ok"},
+		{"invalid check digit", `{"card":"4111111111111112"}`, `{"card":"4111111111111112"}`},
+		{"long id", `{"id":"1234567890123456"}`, `{"id":"1234567890123456"}`},
+		{"email", "mail to tom@example.com", "mail to ***"},
+		{"field", `{"user":"tom","password":"secret"}`, `{"password":"***","user":"tom"}`},
+		{"path", `{"card":{"cvv":"123","name":"tom"},"cvv":"456"}`, `{"card":{"cvv":"***","name":"tom"},"cvv":"456"}`},
+		{"not json", "password=secret", "password=secret"},
+		{"truncated", `{"user":"tom","password":"hunter2","roles":["a","b"],"card":{"cvv":"1`, `{"user":"tom","password":"***","roles":["a","b"],"card":{"cvv":"***"`},
+		{"truncated with marker", `{"password":"hunter2","user":"to...[truncated 20 bytes]`, `{"password":"***","user":"to...[truncated 20 bytes]`},
+		{"truncated in masked value", `{"user":"tom","password":"hunt...[truncated]`, `{"user":"tom","password":"***"...[truncated]`},
+		{"truncated in masked object", `[{"password":{"old":"hunter2","new":"hun`, `[{"password":"***"`},
+		{"invalid", `{"password": hunter2}`, `{"password":"***"`},
+		{"invalid key", `{password: "hunter2"}`, `{***`},
+	}
+	for _, tt := range tests {
+		if got := r.Body(tt.body); got != tt.want {
+			t.Errorf("%s: Body(%s) = %s, want %s", tt.name, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestIsPAN(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"5500-0000-0000-0004", true},
+		{"378282246310005", true},
+		{"1729324800000", false},
+		{"4111111111111112", false},
+		{"411111111111", false},
+	}
+	for _, tt := range tests {
+		if got := IsPAN(tt.s); got != tt.want {
+			t.Errorf("IsPAN(%s) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorUrlAndHeaders(t *testing.T) {
+	r := NewRedactor(RedactConfig{Query: []string{"token"}})
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://x/a?token=abc&b=1", "http://x/a?token=***&b=1"},
+		{"http://x/a?b=1&token=abc#f", "http://x/a?b=1&token=***#f"},
+		{"http://x/a", "http://x/a"},
+	}
+	for _, tt := range tests {
+		if got := r.Url(tt.url); got != tt.want {
+			t.Errorf("Url(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+	h := r.Headers(http.Header{"Authorization": {"Bearer x"}, "Accept": {"text/plain"}})
+	if h["Authorization"][0] != DefaultMask || h["Accept"][0] != "text/plain" {
+		t.Errorf("unexpected headers %v", h)
+	}
+}
+
+func TestDynamicLogKeepsRedactor(t *testing.T) {
+	d := NewDynamicLog(&LogConfig{Redact: &RedactConfig{Fields: []string{"password"}}})
+	r1 := redactorOf(d.Load())
+	d.Store(&LogConfig{Redact: &RedactConfig{Fields: []string{"password"}}})
+	if redactorOf(d.Load()) != r1 {
+		t.Errorf("the redactor must be kept when the redact config is unchanged")
+	}
+	d.Store(&LogConfig{Redact: &RedactConfig{Fields: []string{"secret"}}})
+	if redactorOf(d.Load()) == r1 {
+		t.Errorf("the redactor must be replaced when the redact config changes")
+	}
+}
+
+func TestInitializeLogBindsRedactor(t *testing.T) {
+	c := &LogConfig{Redact: &RedactConfig{Fields: []string{"password"}}}
+	conf := InitializeLog(c)
+	if conf.redactor == nil || redactorOf(conf) != conf.redactor {
+		t.Fatalf("the redactor must be bound to the initialized config")
+	}
+	c.Redact.Fields = []string{"secret"}
+	if InitializeLog(c).redactor == conf.redactor {
+		t.Errorf("each initialized config must have its own redactor")
+	}
+}
+
+func TestGetRedactorIsKeyedByContents(t *testing.T) {
+	c := &RedactConfig{Fields: []string{"password"}}
+	r := GetRedactor(c)
+	if GetRedactor(&RedactConfig{Fields: []string{"password"}}) != r {
+		t.Errorf("the configs with the same contents must share the redactor")
+	}
+	c.Fields = []string{"secret"}
+	if s := GetRedactor(c).Body(`{"password":"y","secret":"x"}`); s != `{"password":"y","secret":"***"}` {
+		t.Errorf("a changed config must not get a stale redactor: %s", s)
+	}
+	for i := 0; i < 2*maxRedactors; i++ {
+		GetRedactor(&RedactConfig{Fields: []string{fmt.Sprint("field", i)}})
+	}
+	redactors.Lock()
+	n := len(redactors.m)
+	redactors.Unlock()
+	if n > maxRedactors {
+		t.Errorf("the cache must be bounded by %d, got %d", maxRedactors, n)
+	}
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Request builds a call of the Client, such as c.R(ctx).Path("/users/{id}").PathParam("id", id).Into(&user).Do().
+type Request struct {
+	client     *Client
+	ctx        context.Context
+	method     string
+	path       string
+	pathParams map[string]string
+	query      url.Values
+	header     map[string]string
+	body       *Body
+	expect     []int
+	result     interface{}
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	conf       *LogConfig
+	options    []func(context.Context, string, map[string]interface{})
+	err        error
+}
+
+// R creates a request builder. The default method is GET, or POST if the request has a body.
+func (c *Client) R(ctx context.Context) *Request {
+	return &Request{client: c, ctx: ctx, conf: c.Config, options: c.options()}
+}
+
+func (r *Request) Method(method string) *Request {
+	r.method = method
+	return r
+}
+
+// Path sets the path, resolved against the url of the client. The {name} parameters are replaced by PathParam.
+func (r *Request) Path(path string) *Request {
+	r.path = path
+	return r
+}
+
+// PathParam sets the value of the {name} parameter of the path; the value is escaped.
+func (r *Request) PathParam(name string, value string) *Request {
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+	r.pathParams[name] = value
+	return r
+}
+
+// Query adds the values of the query parameter; calling it again with the same name repeats the parameter.
+func (r *Request) Query(name string, values ...string) *Request {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	for _, v := range values {
+		r.query.Add(name, v)
+	}
+	return r
+}
+func (r *Request) QueryValues(values url.Values) *Request {
+	for k, v := range values {
+		r.Query(k, v...)
+	}
+	return r
+}
+
+// Header sets a header of the request, which overrides the default headers of the client.
+func (r *Request) Header(name string, value string) *Request {
+	if r.header == nil {
+		r.header = make(map[string]string)
+	}
+	r.header[name] = value
+	return r
+}
+func (r *Request) Headers(headers map[string]string) *Request {
+	for k, v := range headers {
+		r.Header(k, v)
+	}
+	return r
+}
+
+// JSON sets the body of the request, marshalled as Marshal does.
+func (r *Request) JSON(body interface{}) *Request {
+	b, err := marshalBody(context.Background(), body)
+	if err != nil {
+		r.err = err
+	}
+	r.body = b
+	return r
+}
+
+// Encode sets the body of the request, encoded by the codec of the context or the client, JSON by default.
+func (r *Request) Encode(body interface{}) *Request {
+	b, err := marshalBody(r.context(), body)
+	if err != nil {
+		r.err = err
+	}
+	r.body = b
+	return r
+}
+
+// Body sets the body of the request with its content type, such as ReaderBody.
+func (r *Request) Body(body *Body) *Request {
+	if body != nil && body.err != nil {
+		r.err = body.err
+	}
+	r.body = body
+	return r
+}
+
+// Form sets the body of the request as application/x-www-form-urlencoded, from url.Values or a struct (see FormBody).
+func (r *Request) Form(v interface{}, options ...QueryOptions) *Request {
+	return r.Body(FormBody(v, options...))
+}
+
+// Multipart sets the body of the request as multipart/form-data, with the files streamed (see MultipartBody).
+func (r *Request) Multipart(fields interface{}, files ...FilePart) *Request {
+	return r.Body(MultipartBody(fields, files...))
+}
+
+// Expect sets the accepted statuses; any other status returns HttpError. Without Expect, the status policy of the log config is applied.
+func (r *Request) Expect(statuses ...int) *Request {
+	r.expect = statuses
+	return r
+}
+
+// Into sets the value to decode the body into, by the codec of the response Content-Type, when the status is accepted.
+func (r *Request) Into(result interface{}) *Request {
+	r.result = result
+	return r
+}
+
+// Timeout sets the timeout of each attempt.
+func (r *Request) Timeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// Retry retries the retryable errors (see IsRetryable) up to retries times; the backoff is doubled for each retry.
+// A request with a streamed body is not retried, because the body cannot be read again.
+func (r *Request) Retry(retries int, backoff time.Duration) *Request {
+	r.retries = retries
+	r.backoff = backoff
+	return r
+}
+
+// Log overrides the log config of the client, and the log functions (logError, logInfo) if they are passed.
+func (r *Request) Log(conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) *Request {
+	r.conf = conf
+	if len(options) > 0 {
+		r.options = options
+	}
+	return r
+}
+
+func (r *Request) Get() (*Response, error) {
+	return r.Method(http.MethodGet).Do()
+}
+func (r *Request) Post() (*Response, error) {
+	return r.Method(http.MethodPost).Do()
+}
+func (r *Request) Put() (*Response, error) {
+	return r.Method(http.MethodPut).Do()
+}
+func (r *Request) Patch() (*Response, error) {
+	return r.Method(http.MethodPatch).Do()
+}
+func (r *Request) Delete() (*Response, error) {
+	return r.Method(http.MethodDelete).Do()
+}
+
+// Url returns the url of the request, with the escaped path parameters and the query parameters.
+func (r *Request) Url() string {
+	path := r.path
+	for k, v := range r.pathParams {
+		path = strings.ReplaceAll(path, "{"+k+"}", url.PathEscape(v))
+	}
+	u := r.client.ResolveUrl(path)
+	if len(r.query) > 0 {
+		if strings.Contains(u, "?") {
+			u = u + "&" + r.query.Encode()
+		} else {
+			u = u + "?" + r.query.Encode()
+		}
+	}
+	return u
+}
+
+// Do sends the request. If the response has a status, the Response is returned, even with an error.
+func (r *Request) Do() (*Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	method := r.method
+	if len(method) == 0 {
+		method = http.MethodGet
+		if r.body != nil && r.body.Length != 0 {
+			method = http.MethodPost
+		}
+	}
+	ctx := r.context()
+	if len(r.expect) > 0 {
+		ctx = WithStatusPolicy(ctx, StrictStatus(r.expect...))
+	}
+	if len(r.path) > 0 && len(GetRoute(ctx)) == 0 {
+		ctx = WithRoute(ctx, r.path)
+	}
+	u := r.Url()
+	start := time.Now()
+	backoff := r.backoff
+	for attempt := 1; ; attempt++ {
+		res, err := r.send(WithAttempt(ctx, attempt), method, u, start, attempt)
+		if err == nil || attempt > r.retries || !IsRetryable(err) || (r.body != nil && r.body.Streamed()) {
+			if err == nil && r.result != nil {
+				err = res.Decode(r.result)
+			}
+			return res, err
+		}
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return res, err
+			case <-t.C:
+			}
+			backoff *= 2
+		}
+	}
+}
+func (r *Request) context() context.Context {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.client.withCodec(ctx)
+}
+func (r *Request) send(ctx context.Context, method string, u string, start time.Time, attempt int) (*Response, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	return sendResponse(ctx, start, attempt, func(ctx context.Context) (*http.Response, error) {
+		return execute(ctx, r.client.Client, method, u, r.body, r.client.MergeHeaders(r.header), r.conf, r.options, statusInterceptor)
+	})
+}
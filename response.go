@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Response is the response of the request builder and DoResponse. The body is fully read and closed, so that the connection is reused.
+type Response struct {
+	Response   *http.Response
+	Duration   time.Duration
+	Attempts   int
+	RemoteAddr string
+	body       []byte
+}
+
+// newResponse reads and closes the body of res.
+func newResponse(res *http.Response, start time.Time, attempts int) (*Response, error) {
+	r := &Response{Response: res, Attempts: attempts}
+	var err error
+	if res.Body != nil {
+		r.body, err = io.ReadAll(res.Body)
+		res.Body.Close()
+	}
+	r.Duration = time.Since(start)
+	return r, err
+}
+
+func (r *Response) Status() int {
+	return r.Response.StatusCode
+}
+func (r *Response) Header() http.Header {
+	return r.Response.Header
+}
+
+// Proto returns the protocol of the response, such as "HTTP/1.1" or "HTTP/2.0".
+func (r *Response) Proto() string {
+	return r.Response.Proto
+}
+func (r *Response) Bytes() []byte {
+	return r.body
+}
+func (r *Response) String() string {
+	return string(r.body)
+}
+
+// JSON decodes the body into v. An empty body is not decoded.
+func (r *Response) JSON(v interface{}) error {
+	if len(r.body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.body, v)
+}
+
+// Decode decodes the body into v by the codec of the Content-Type (see LookupCodec), JSON by default. An empty body is not decoded.
+func (r *Response) Decode(v interface{}) error {
+	if len(r.body) == 0 {
+		return nil
+	}
+	if c := responseCodec(r.Response); c != nil {
+		return c.Unmarshal(r.body, v)
+	}
+	return json.Unmarshal(r.body, v)
+}
+
+// XML decodes the body into v. An empty body is not decoded.
+func (r *Response) XML(v interface{}) error {
+	if len(r.body) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(r.body, v)
+}
+
+// DoResponse sends the request through the interceptors of conf, like DoAndLog, and returns the Response with the body read and closed.
+// If the server responded, the Response is returned even with an error.
+func DoResponse(ctx context.Context, client Doer, method string, url string, body []byte, headers map[string]string, conf *LogConfig, options ...func(context.Context, string, map[string]interface{})) (*Response, error) {
+	return sendResponse(ctx, time.Now(), 1, func(ctx context.Context) (*http.Response, error) {
+		return DoAndLog(ctx, client, method, url, body, headers, conf, options...)
+	})
+}
+
+func sendResponse(ctx context.Context, start time.Time, attempt int, send func(context.Context) (*http.Response, error)) (*Response, error) {
+	ctx, addr := traceRemoteAddr(ctx)
+	res, err := send(ctx)
+	if res == nil {
+		return nil, err
+	}
+	rs, er1 := newResponse(res, start, attempt)
+	rs.RemoteAddr = addr.get()
+	if err == nil {
+		err = er1
+	}
+	return rs, err
+}
+
+type remoteAddr struct {
+	mu   sync.Mutex
+	addr string
+}
+
+func (a *remoteAddr) get() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.addr
+}
+func traceRemoteAddr(ctx context.Context) (context.Context, *remoteAddr) {
+	a := &remoteAddr{}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn != nil {
+				a.mu.Lock()
+				a.addr = info.Conn.RemoteAddr().String()
+				a.mu.Unlock()
+			}
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), a
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SamplingConfig struct {
+	Rate      float64            `yaml:"rate" mapstructure:"rate" json:"rate,omitempty" gorm:"column:rate" bson:"rate,omitempty" dynamodbav:"rate,omitempty" firestore:"rate,omitempty"`
+	Endpoints map[string]float64 `yaml:"endpoints" mapstructure:"endpoints" json:"endpoints,omitempty" gorm:"column:endpoints" bson:"endpoints,omitempty" dynamodbav:"endpoints,omitempty" firestore:"endpoints,omitempty"`
+	Slow      time.Duration      `yaml:"slow" mapstructure:"slow" json:"slow,omitempty" gorm:"column:slow" bson:"slow,omitempty" dynamodbav:"slow,omitempty" firestore:"slow,omitempty"`
+	Error     bool               `yaml:"error" mapstructure:"error" json:"error,omitempty" gorm:"column:error" bson:"error,omitempty" dynamodbav:"error,omitempty" firestore:"error,omitempty"`
+	Window    time.Duration      `yaml:"window" mapstructure:"window" json:"window,omitempty" gorm:"column:window" bson:"window,omitempty" dynamodbav:"window,omitempty" firestore:"window,omitempty"`
+	Repeated  string             `yaml:"repeated" mapstructure:"repeated" json:"repeated,omitempty" gorm:"column:repeated" bson:"repeated,omitempty" dynamodbav:"repeated,omitempty" firestore:"repeated,omitempty"`
+}
+
+type suppressed struct {
+	key    string
+	since  time.Time
+	count  int
+	ctx    context.Context
+	msg    string
+	fields map[string]interface{}
+	log    func(context.Context, string, map[string]interface{})
+	timer  *time.Timer
+}
+
+// Sampler decides which calls are logged. It keeps the state to suppress the identical consecutive error logs.
+type Sampler struct {
+	Config SamplingConfig
+	mu     sync.Mutex
+	last   *suppressed
+}
+
+// maxSamplers is the size of the cache of GetSampler; the cache is cleared, and the evicted samplers are flushed, when it is full.
+const maxSamplers = 64
+
+var samplers = struct {
+	sync.Mutex
+	m map[string]*Sampler
+}{m: make(map[string]*Sampler)}
+
+// GetSampler returns the sampler of the config. The samplers are cached by the contents of the config, so that a config which is changed gets a new sampler.
+// The configs initialized by InitializeLog or stored into a DynamicLog have their own sampler, and do not use this cache.
+func GetSampler(c *SamplingConfig) *Sampler {
+	key := fmt.Sprintf("%+v", *c)
+	samplers.Lock()
+	if s, ok := samplers.m[key]; ok {
+		samplers.Unlock()
+		return s
+	}
+	var evicted map[string]*Sampler
+	if len(samplers.m) >= maxSamplers {
+		evicted = samplers.m
+		samplers.m = make(map[string]*Sampler)
+	}
+	s := NewSampler(*c)
+	samplers.m[key] = s
+	samplers.Unlock()
+	for _, e := range evicted {
+		e.Flush()
+	}
+	return s
+}
+func NewSampler(c SamplingConfig) *Sampler {
+	if len(c.Repeated) == 0 {
+		c.Repeated = "repeated"
+	}
+	return &Sampler{Config: c}
+}
+
+// samplerOf returns the sampler bound to conf by InitializeLog or DynamicLog, or the cached sampler of conf.Sampling.
+func samplerOf(conf *LogConfig) *Sampler {
+	if conf.sampler != nil {
+		return conf.sampler
+	}
+	return GetSampler(conf.Sampling)
+}
+
+// Rate returns the sampling rate of the url: the rate of the longest matched endpoint (url prefix or host), or the default rate.
+func (s *Sampler) Rate(u string) float64 {
+	rate := s.Config.Rate
+	matched := -1
+	var host string
+	if x, err := url.Parse(u); err == nil {
+		host = x.Host
+	}
+	for k, r := range s.Config.Endpoints {
+		if (strings.HasPrefix(u, k) || k == host) && len(k) > matched {
+			rate, matched = r, len(k)
+		}
+	}
+	return rate
+}
+
+// Sample draws once per call, so that the separated request log and the response log are kept or dropped together.
+func (s *Sampler) Sample(u string) bool {
+	rate := s.Rate(u)
+	if rate >= 1 {
+		return true
+	}
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Dedup returns false if the log is identical to the previous error log within the window.
+// When a different log comes, or the window expires, the last suppressed log is emitted with the number of repetitions.
+func (s *Sampler) Dedup(ctx context.Context, key string, msg string, fields map[string]interface{}, log func(context.Context, string, map[string]interface{})) bool {
+	if s.Config.Window <= 0 {
+		return true
+	}
+	now := time.Now()
+	s.mu.Lock()
+	last := s.last
+	if last != nil && last.key == key && now.Sub(last.since) < s.Config.Window {
+		last.count++
+		last.ctx, last.msg, last.fields, last.log = ctx, msg, fields, log
+		if last.timer == nil {
+			last.timer = time.AfterFunc(s.Config.Window-now.Sub(last.since), func() {
+				s.expire(last)
+			})
+		}
+		s.mu.Unlock()
+		return false
+	}
+	s.last = &suppressed{key: key, since: now, ctx: ctx, msg: msg, fields: fields, log: log}
+	s.mu.Unlock()
+	if last != nil && last.count > 0 {
+		if last.timer != nil {
+			last.timer.Stop()
+		}
+		if last.key == key {
+			fields[s.Config.Repeated] = last.count
+		} else {
+			last.fields[s.Config.Repeated] = last.count
+			last.log(last.ctx, last.msg, last.fields)
+		}
+	}
+	return true
+}
+
+// expire emits the suppressed log when the window expires, if no other log has come.
+func (s *Sampler) expire(last *suppressed) {
+	s.mu.Lock()
+	if s.last != last || last.count == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.last = nil
+	s.mu.Unlock()
+	last.fields[s.Config.Repeated] = last.count
+	last.log(last.ctx, last.msg, last.fields)
+}
+
+// Flush emits the suppressed log without waiting for the window to expire, such as before shutdown.
+func (s *Sampler) Flush() {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+	if last != nil {
+		if last.timer != nil {
+			last.timer.Stop()
+		}
+		s.expire(last)
+	}
+}
+
+// SampleLog wraps a log function to apply sampling, the slow call threshold and the deduplication of errors.
+func SampleLog(conf *LogConfig, log func(context.Context, string, map[string]interface{}), s *Sampler, sampled bool) func(context.Context, string, map[string]interface{}) {
+	if log == nil {
+		return nil
+	}
+	status, errorKey, duration := "status", "error", "duration"
+	if len(conf.ResponseStatus) > 0 {
+		status = conf.ResponseStatus
+	}
+	if len(conf.Error) > 0 {
+		errorKey = conf.Error
+	}
+	if len(conf.Duration) > 0 {
+		duration = conf.Duration
+	}
+	return func(ctx context.Context, msg string, fields map[string]interface{}) {
+		code, _ := fields[status].(int)
+		e, isError := fields[errorKey]
+		isError = isError || code >= 400
+		if isError {
+			key := fmt.Sprint(msg, " ", code, " ", e)
+			if !s.Dedup(ctx, key, msg, fields, log) {
+				return
+			}
+			if s.Config.Error {
+				log(ctx, msg, fields)
+				return
+			}
+		}
+		if s.Config.Slow > 0 {
+			if d, ok := fields[duration].(int64); ok && d >= s.Config.Slow.Milliseconds() {
+				log(ctx, msg, fields)
+				return
+			}
+		}
+		if sampled {
+			log(ctx, msg, fields)
+		}
+	}
+}
+
+// SampleOptions turns on the logs of conf, and wraps the log functions to filter them by the sampler.
+// Returns conf itself if sampling is not configured.
+func SampleOptions(conf *LogConfig, url string, options []func(context.Context, string, map[string]interface{})) (*LogConfig, []func(context.Context, string, map[string]interface{})) {
+	if conf == nil || conf.Sampling == nil {
+		return conf, options
+	}
+	s := samplerOf(conf)
+	sampled := s.Sample(url)
+	opts := wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return SampleLog(conf, log, s, sampled)
+	})
+	if !conf.Log {
+		c2 := *conf
+		c2.Log = true
+		if len(c2.Duration) == 0 {
+			c2.Duration = "duration"
+		}
+		conf = &c2
+	}
+	return conf, opts
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSamplerRate(t *testing.T) {
+	s := NewSampler(SamplingConfig{Rate: 0.1, Endpoints: map[string]float64{
+		"api.example.com":              0.5,
+		"https://api.example.com/v1":   1,
+		"https://api.example.com/v1/x": 0,
+	}})
+	tests := []struct {
+		url  string
+		want float64
+	}{
+		{"https://other.com/a", 0.1},
+		{"https://api.example.com/v2", 0.5},
+		{"https://api.example.com/v1/users", 1},
+		{"https://api.example.com/v1/x/1", 0},
+	}
+	for _, tt := range tests {
+		if got := s.Rate(tt.url); got != tt.want {
+			t.Errorf("Rate(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+	if !s.Sample("https://api.example.com/v1/users") || s.Sample("https://api.example.com/v1/x/1") {
+		t.Errorf("rate 1 must always be sampled, rate 0 never")
+	}
+}
+
+type logRecorder struct {
+	mu   sync.Mutex
+	logs []map[string]interface{}
+}
+
+func (r *logRecorder) log(ctx context.Context, msg string, fields map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, fields)
+}
+func (r *logRecorder) get() []map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]interface{}(nil), r.logs...)
+}
+
+func TestSampleLog(t *testing.T) {
+	conf := &LogConfig{}
+	tests := []struct {
+		name    string
+		config  SamplingConfig
+		sampled bool
+		fields  map[string]interface{}
+		want    bool
+	}{
+		{"sampled", SamplingConfig{}, true, map[string]interface{}{"status": 200}, true},
+		{"not sampled", SamplingConfig{}, false, map[string]interface{}{"status": 200}, false},
+		{"error kept", SamplingConfig{Error: true}, false, map[string]interface{}{"status": 500}, true},
+		{"network error kept", SamplingConfig{Error: true}, false, map[string]interface{}{"error": "refused"}, true},
+		{"error not kept", SamplingConfig{}, false, map[string]interface{}{"status": 500}, false},
+		{"slow kept", SamplingConfig{Slow: time.Second}, false, map[string]interface{}{"status": 200, "duration": int64(1500)}, true},
+		{"fast dropped", SamplingConfig{Slow: time.Second}, false, map[string]interface{}{"status": 200, "duration": int64(10)}, false},
+	}
+	for _, tt := range tests {
+		r := &logRecorder{}
+		SampleLog(conf, r.log, NewSampler(tt.config), tt.sampled)(context.Background(), "GET http://x", tt.fields)
+		if got := len(r.get()) == 1; got != tt.want {
+			t.Errorf("%s: logged = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDedupFlushesWhenWindowExpires(t *testing.T) {
+	s := NewSampler(SamplingConfig{Error: true, Window: 50 * time.Millisecond})
+	r := &logRecorder{}
+	log := SampleLog(&LogConfig{}, r.log, s, true)
+	for i := 0; i < 5; i++ {
+		log(context.Background(), "GET http://x", map[string]interface{}{"status": 500})
+	}
+	if n := len(r.get()); n != 1 {
+		t.Fatalf("identical errors must be suppressed, got %d logs", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	logs := r.get()
+	if len(logs) != 2 || logs[1]["repeated"] != 4 {
+		t.Fatalf("the suppressed count must be flushed when the window expires, got %v", logs)
+	}
+	// after the window, the same error is logged again
+	log(context.Background(), "GET http://x", map[string]interface{}{"status": 500})
+	if n := len(r.get()); n != 3 {
+		t.Errorf("expected a new log after the window, got %d logs", n)
+	}
+}
+
+func TestDedupFlushesOnDifferentLog(t *testing.T) {
+	s := NewSampler(SamplingConfig{Error: true, Window: time.Minute})
+	r := &logRecorder{}
+	log := SampleLog(&LogConfig{}, r.log, s, true)
+	log(context.Background(), "GET http://x", map[string]interface{}{"status": 500})
+	log(context.Background(), "GET http://x", map[string]interface{}{"status": 500})
+	log(context.Background(), "GET http://y", map[string]interface{}{"status": 502})
+	logs := r.get()
+	if len(logs) != 3 || logs[1]["repeated"] != 1 || logs[2]["status"] != 502 {
+		t.Errorf("unexpected logs %v", logs)
+	}
+	s.Flush()
+	if n := len(r.get()); n != 3 {
+		t.Errorf("nothing is suppressed, Flush must not log, got %d logs", n)
+	}
+}
+
+func TestDynamicLogKeepsSampler(t *testing.T) {
+	d := NewDynamicLog(&LogConfig{Sampling: &SamplingConfig{Rate: 1, Window: time.Minute}})
+	s1 := samplerOf(d.Load())
+	d.Store(&LogConfig{Sampling: &SamplingConfig{Rate: 1, Window: time.Minute}})
+	if samplerOf(d.Load()) != s1 {
+		t.Errorf("the sampler must be kept when the sampling config is unchanged")
+	}
+	d.Store(&LogConfig{Sampling: &SamplingConfig{Rate: 0.5}})
+	if samplerOf(d.Load()) == s1 {
+		t.Errorf("the sampler must be replaced when the sampling config changes")
+	}
+}
+
+func TestInitializeLogBindsSampler(t *testing.T) {
+	c := &LogConfig{Sampling: &SamplingConfig{Rate: 1}}
+	conf := InitializeLog(c)
+	if conf.sampler == nil || samplerOf(conf) != conf.sampler {
+		t.Fatalf("the sampler must be bound to the initialized config")
+	}
+	if InitializeLog(c).sampler == conf.sampler {
+		t.Errorf("each initialized config must have its own sampler")
+	}
+}
+
+func TestGetSamplerIsKeyedByContents(t *testing.T) {
+	c := &SamplingConfig{Rate: 0.25}
+	s := GetSampler(c)
+	if GetSampler(&SamplingConfig{Rate: 0.25}) != s {
+		t.Errorf("the configs with the same contents must share the sampler")
+	}
+	c.Rate = 0.75
+	if r := GetSampler(c).Rate("http://localhost/users"); r != 0.75 {
+		t.Errorf("a changed config must not get a stale sampler, rate %v", r)
+	}
+	for i := 0; i < 2*maxSamplers; i++ {
+		GetSampler(&SamplingConfig{Rate: float64(i) / 1000})
+	}
+	samplers.Lock()
+	n := len(samplers.m)
+	samplers.Unlock()
+	if n > maxSamplers {
+		t.Errorf("the cache must be bounded by %d, got %d", maxSamplers, n)
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type StatusPolicy struct {
+	Strict   bool  `yaml:"strict" mapstructure:"strict" json:"strict,omitempty" gorm:"column:strict" bson:"strict,omitempty" dynamodbav:"strict,omitempty" firestore:"strict,omitempty"`
+	Expected []int `yaml:"expected" mapstructure:"expected" json:"expected,omitempty" gorm:"column:expected" bson:"expected,omitempty" dynamodbav:"expected,omitempty" firestore:"expected,omitempty"`
+}
+
+type statusPolicyKey struct{}
+
+// StrictStatus returns a policy that turns every status outside of expected into an HttpError. If expected is empty, any 2xx status is accepted.
+func StrictStatus(expected ...int) *StatusPolicy {
+	return &StatusPolicy{Strict: true, Expected: expected}
+}
+
+// LenientStatus keeps the legacy behavior: the body is decoded whatever the status is, only 503 is an HttpError.
+func LenientStatus() *StatusPolicy {
+	return &StatusPolicy{}
+}
+
+// WithStatusPolicy overrides the status policy of LogConfig for a single call.
+func WithStatusPolicy(ctx context.Context, policy *StatusPolicy) context.Context {
+	return context.WithValue(ctx, statusPolicyKey{}, policy)
+}
+func GetStatusPolicy(ctx context.Context, conf *LogConfig) *StatusPolicy {
+	if ctx != nil {
+		if p, ok := ctx.Value(statusPolicyKey{}).(*StatusPolicy); ok && p != nil {
+			return p
+		}
+	}
+	if conf != nil && conf.Status != nil {
+		return conf.Status
+	}
+	return nil
+}
+func (p *StatusPolicy) Accept(status int) bool {
+	if p == nil || !p.Strict {
+		return true
+	}
+	if len(p.Expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range p.Expected {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+func checkStatus(ctx context.Context, conf *LogConfig, res *http.Response, dur int64, url string, body []byte) error {
+	policy := GetStatusPolicy(ctx, conf)
+	if policy.Accept(res.StatusCode) {
+		return nil
+	}
+	b, _ := bufferBody(res)
+	var rq string
+	if body != nil {
+		rq = string(body)
+	}
+	err := NewHttpError(res.StatusCode, nil, dur, fmt.Sprint("Response error with status code: ", res.StatusCode), url, rq, string(b))
+	var problem *ProblemConfig
+	if conf != nil {
+		problem = conf.Problem
+	}
+	SetProblem(err.(*HttpError), res.Header.Get("Content-Type"), b, problem)
+	return err
+}
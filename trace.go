@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+	BaggageHeader     = "baggage"
+	B3Header          = "b3"
+)
+
+// SpanContext is the W3C trace context of the current span: 32 hex digits trace id, 16 hex digits span id, flags and trace state.
+type SpanContext struct {
+	TraceID    string
+	SpanID     string
+	Flags      byte
+	TraceState string
+}
+
+func (s SpanContext) Valid() bool {
+	return isHex(s.TraceID, 32) && isHex(s.SpanID, 16) && strings.Trim(s.TraceID, "0") != "" && strings.Trim(s.SpanID, "0") != ""
+}
+func (s SpanContext) Sampled() bool {
+	return s.Flags&1 == 1
+}
+func (s SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", s.TraceID, s.SpanID, s.Flags)
+}
+func ParseTraceParent(s string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	sc := SpanContext{TraceID: parts[1], SpanID: parts[2], Flags: flags[0]}
+	if !sc.Valid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+type baggageKey struct{}
+type propagatorKey struct{}
+
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+func GetSpanContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.Valid()
+}
+func WithBaggage(ctx context.Context, baggage map[string]string) context.Context {
+	return context.WithValue(ctx, baggageKey{}, baggage)
+}
+func GetBaggage(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	b, _ := ctx.Value(baggageKey{}).(map[string]string)
+	return b
+}
+
+// Propagator injects the trace context of ctx into the headers of the outbound request.
+type Propagator interface {
+	Inject(ctx context.Context, header http.Header)
+}
+
+type TraceContextPropagator struct{}
+
+func (p TraceContextPropagator) Inject(ctx context.Context, header http.Header) {
+	sc, ok := GetSpanContext(ctx)
+	if !ok {
+		return
+	}
+	header.Set(TraceParentHeader, sc.TraceParent())
+	if len(sc.TraceState) > 0 {
+		header.Set(TraceStateHeader, sc.TraceState)
+	}
+}
+
+type BaggagePropagator struct{}
+
+func (p BaggagePropagator) Inject(ctx context.Context, header http.Header) {
+	b := GetBaggage(ctx)
+	if len(b) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	members := make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, url.PathEscape(k)+"="+url.PathEscape(b[k]))
+	}
+	header.Set(BaggageHeader, strings.Join(members, ","))
+}
+
+// B3Propagator injects the Zipkin B3 headers, either the single "b3" header or the multiple X-B3-* headers.
+type B3Propagator struct {
+	SingleHeader bool
+}
+
+func (p B3Propagator) Inject(ctx context.Context, header http.Header) {
+	sc, ok := GetSpanContext(ctx)
+	if !ok {
+		return
+	}
+	sampled := "0"
+	if sc.Sampled() {
+		sampled = "1"
+	}
+	if p.SingleHeader {
+		header.Set(B3Header, sc.TraceID+"-"+sc.SpanID+"-"+sampled)
+		return
+	}
+	header.Set("X-B3-TraceId", sc.TraceID)
+	header.Set("X-B3-SpanId", sc.SpanID)
+	header.Set("X-B3-Sampled", sampled)
+}
+
+type CompositePropagator []Propagator
+
+func (p CompositePropagator) Inject(ctx context.Context, header http.Header) {
+	for _, x := range p {
+		x.Inject(ctx, header)
+	}
+}
+
+// DefaultPropagator is used by AddHeaderAndDo and AddHeaderAndDoJSON, unless a propagator is set into the context by WithPropagator.
+var DefaultPropagator Propagator = CompositePropagator{TraceContextPropagator{}, BaggagePropagator{}}
+
+func WithPropagator(ctx context.Context, p Propagator) context.Context {
+	return context.WithValue(ctx, propagatorKey{}, p)
+}
+
+// Inject injects the trace headers, without overriding the headers set by the caller: each header is injected only if the caller has not set it.
+// tracestate is not injected if the caller has set traceparent, and the X-B3-* headers are not injected if the caller has set one of them.
+func Inject(ctx context.Context, header http.Header) {
+	if ctx == nil {
+		return
+	}
+	p, ok := ctx.Value(propagatorKey{}).(Propagator)
+	if !ok {
+		p = DefaultPropagator
+	}
+	if p == nil {
+		return
+	}
+	h := make(http.Header)
+	p.Inject(ctx, h)
+	for k := range h {
+		if hasTraceHeader(header, k) {
+			h[k] = nil
+		}
+	}
+	for k, v := range h {
+		if v != nil {
+			header[k] = v
+		}
+	}
+}
+func hasTraceHeader(header http.Header, key string) bool {
+	if len(header.Values(key)) > 0 {
+		return true
+	}
+	if strings.EqualFold(key, TraceStateHeader) {
+		return len(header.Values(TraceParentHeader)) > 0
+	}
+	if strings.HasPrefix(http.CanonicalHeaderKey(key), "X-B3-") {
+		for k := range header {
+			if strings.HasPrefix(http.CanonicalHeaderKey(k), "X-B3-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extract reads traceparent, tracestate and baggage of an inbound request into the context.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, err := ParseTraceParent(header.Get(TraceParentHeader)); err == nil {
+		sc.TraceState = header.Get(TraceStateHeader)
+		ctx = WithSpanContext(ctx, sc)
+	}
+	if s := header.Get(BaggageHeader); len(s) > 0 {
+		b := make(map[string]string)
+		for _, m := range strings.Split(s, ",") {
+			m = strings.TrimSpace(m)
+			if i := strings.Index(m, ";"); i >= 0 {
+				m = m[:i]
+			}
+			if i := strings.Index(m, "="); i > 0 {
+				k, er1 := url.PathUnescape(strings.TrimSpace(m[:i]))
+				v, er2 := url.PathUnescape(strings.TrimSpace(m[i+1:]))
+				if er1 == nil && er2 == nil {
+					b[k] = v
+				}
+			}
+		}
+		if len(b) > 0 {
+			ctx = WithBaggage(ctx, b)
+		}
+	}
+	return ctx
+}
+
+// Tracer creates a client span for every call. The url is redacted by the redact config (the query parameters are masked). The returned context must carry the span context of the new span (by WithSpanContext),
+// the returned function ends the span with the status code and the error of the call.
+type Tracer interface {
+	Start(ctx context.Context, method string, url string) (context.Context, func(status int, err error))
+}
+
+func StartSpan(ctx context.Context, conf *LogConfig, method string, url string) (context.Context, func(*HeaderRecorder, error)) {
+	if conf == nil || conf.Tracer == nil {
+		return ctx, func(*HeaderRecorder, error) {}
+	}
+	ctx, end := conf.Tracer.Start(ctx, method, redactorOf(conf).Url(url))
+	return ctx, func(rec *HeaderRecorder, err error) {
+		status, _ := rec.Status()
+		if e, ok := IsHttpError(err); ok && status == 0 {
+			status = e.StatusCode
+		}
+		end(status, err)
+	}
+}
+
+// LogTrace wraps a log function to add the trace id and span id of the context into the trace_id, span_id fields.
+func LogTrace(conf *LogConfig, options []func(context.Context, string, map[string]interface{})) []func(context.Context, string, map[string]interface{}) {
+	if conf == nil || (len(conf.TraceId) == 0 && len(conf.SpanId) == 0) {
+		return options
+	}
+	return wrapOptions(options, func(log func(context.Context, string, map[string]interface{})) func(context.Context, string, map[string]interface{}) {
+		return func(ctx context.Context, msg string, fields map[string]interface{}) {
+			if sc, ok := GetSpanContext(ctx); ok {
+				if len(conf.TraceId) > 0 {
+					fields[conf.TraceId] = sc.TraceID
+				}
+				if len(conf.SpanId) > 0 {
+					fields[conf.SpanId] = sc.SpanID
+				}
+			}
+			log(ctx, msg, fields)
+		}
+	})
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInjectKeepsCallerHeaders(t *testing.T) {
+	sc := SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: 1, TraceState: "k=v"}
+	ctx := WithBaggage(WithSpanContext(context.Background(), sc), map[string]string{"user": "tom"})
+	all := CompositePropagator{TraceContextPropagator{}, BaggagePropagator{}, B3Propagator{}}
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{"empty", http.Header{}, map[string]string{"Traceparent": sc.TraceParent(), "Tracestate": "k=v", "Baggage": "user=tom", "X-B3-Traceid": sc.TraceID}},
+		{"baggage", http.Header{"Baggage": {"a=b"}}, map[string]string{"Traceparent": sc.TraceParent(), "Baggage": "a=b"}},
+		{"traceparent", http.Header{"Traceparent": {"00-x-y-01"}}, map[string]string{"Traceparent": "00-x-y-01", "Tracestate": "", "Baggage": "user=tom"}},
+		{"b3", http.Header{"X-B3-Traceid": {"abc"}}, map[string]string{"X-B3-Traceid": "abc", "X-B3-Spanid": "", "Traceparent": sc.TraceParent()}},
+	}
+	for _, tt := range tests {
+		Inject(WithPropagator(ctx, all), tt.header)
+		for k, v := range tt.want {
+			if got := tt.header.Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+	}
+}
+
+type urlTracer struct {
+	url string
+}
+
+func (t *urlTracer) Start(ctx context.Context, method string, url string) (context.Context, func(int, error)) {
+	t.url = url
+	return ctx, func(int, error) {}
+}
+
+func TestSpanUrlIsRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tracer := &urlTracer{}
+	conf := &LogConfig{Tracer: tracer, Redact: &RedactConfig{Query: []string{"token"}}}
+	res, err := DoAndLog(context.Background(), srv.Client(), http.MethodGet, srv.URL+"/a?token=secret&b=1", nil, nil, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res.Body)
+	if want := srv.URL + "/a?token=***&b=1"; tracer.url != want {
+		t.Errorf("span url = %s, want %s", tracer.url, want)
+	}
+}